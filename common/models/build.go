@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	BuildStatusQueued    = "queued"
+	BuildStatusRunning   = "running"
+	BuildStatusSucceeded = "succeeded"
+	BuildStatusFailed    = "failed"
+)
+
+type Build struct {
+	ID        string `json:"id,omitempty"`
+	ToasterID string `json:"toaster_id,omitempty"`
+	// Version is the version of the toaster being built.
+	Version int `json:"version,omitempty"`
+
+	Status string `json:"status,omitempty"`
+	// Stage and Progress, from 0 to 100, tell how far a running build is.
+	Stage    string `json:"stage,omitempty"`
+	Progress int    `json:"progress,omitempty"`
+	// Error tells why a failed build failed.
+	Error string `json:"error,omitempty"`
+
+	StartTime time.Time `json:"start_time"`
+	// EndTime is zero until the build is done.
+	EndTime time.Time `json:"end_time"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	ExecutionStatusRunning   = "running"
+	ExecutionStatusSucceeded = "succeeded"
+	ExecutionStatusFailed    = "failed"
+	ExecutionStatusTimedOut  = "timed_out"
+	ExecutionStatusStopped   = "stopped"
+	ExecutionStatusKilled    = "killed"
+)
+
+type Execution struct {
+	ID        string `json:"id,omitempty"`
+	ToasterID string `json:"toaster_id,omitempty"`
+	Forced    bool   `json:"forced,omitempty"`
+
+	Status string `json:"status,omitempty"`
+	// ExitCode is only meaningful once the execution has ended.
+	ExitCode int `json:"exit_code"`
+
+	StartTime time.Time `json:"start_time"`
+	// EndTime is zero while the execution is running.
+	EndTime time.Time `json:"end_time"`
+
+	// JoinerCount is the number of requests that joined the execution.
+	JoinerCount int `json:"joiner_count,omitempty"`
+
+	Stats *ToasterStats `json:"stats,omitempty"`
+}
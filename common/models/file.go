@@ -0,0 +1,9 @@
+package models
+
+// FileHash describes a file of the code of a toaster by its content.
+type FileHash struct {
+	Path string `json:"path,omitempty"`
+	// SHA256 is the hex encoded hash of the content of the file.
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
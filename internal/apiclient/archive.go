@@ -0,0 +1,127 @@
+package apiclient
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// ArchiveFormat is the format of code uploaded as a single archive.
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// archiveModTime is the modification time of every archived file, so that
+// archives of the same files are identical. Zip can not go before 1980.
+var archiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (f ArchiveFormat) validate() error {
+	switch f {
+	case ArchiveTarGz, ArchiveZip:
+		return nil
+	default:
+		return fmt.Errorf("unsupported archive format %q", f)
+	}
+}
+
+// archiveWriter packs files in an archive.
+type archiveWriter interface {
+	// add archives a file of size bytes read from r, size being -1 when
+	// unknown.
+	add(name string, mode fs.FileMode, size int64, r io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(format ArchiveFormat, w io.Writer) (archiveWriter, error) {
+	switch format {
+	case ArchiveTarGz:
+		// The zero header of the gzip writer has no name nor time.
+		gz := gzip.NewWriter(w)
+		return &tarGzWriter{gz: gz, tw: tar.NewWriter(gz)}, nil
+	case ArchiveZip:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, format.validate()
+	}
+}
+
+// archiveMode keeps the executable bit of files, and nothing else.
+func archiveMode(mode fs.FileMode) fs.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+type tarGzWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (w *tarGzWriter) add(name string, mode fs.FileMode, size int64, r io.Reader) error {
+	if size < 0 {
+		// Tar headers come with the size of the file.
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, r)
+		if err != nil {
+			return err
+		}
+		size, r = n, &buf
+	}
+
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(archiveMode(mode)),
+		Size:     size,
+		ModTime:  archiveModTime,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(w.tw, r, size)
+	return err
+}
+
+func (w *tarGzWriter) Close() error {
+	err := w.tw.Close()
+	if err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) add(name string, mode fs.FileMode, size int64, r io.Reader) error {
+	h := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: archiveModTime,
+	}
+	h.SetMode(archiveMode(mode))
+
+	fw, err := w.zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
@@ -0,0 +1,72 @@
+package apiclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	authTokenHeader     = "X-TOASTATE-AUTH"
+	apiKeyHeader        = "X-TOASTATE-APIKEY"
+	timestampHeader     = "X-TOASTATE-TIMESTAMP"
+	contentSHA256Header = "X-TOASTATE-CONTENT-SHA256"
+	signatureHeader     = "X-TOASTATE-SIGNATURE"
+
+	// unsignedPayload replaces the content hash of streamed bodies, which
+	// can not be hashed before being sent.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var emptySHA256 = sha256Hex(nil)
+
+func (c *Client) SetAuthToken(token string) *Client {
+	c.authToken = token
+	c.apiKey = ""
+	c.apiSecret = ""
+	return c
+}
+
+// SetAPIKey authenticates requests with an API key. When secret is not
+// empty, every request is also signed with it.
+func (c *Client) SetAPIKey(key, secret string) *Client {
+	c.authToken = ""
+	c.apiKey = key
+	c.apiSecret = secret
+	return c
+}
+
+// authenticate adds the authentication headers to req. contentHash is the
+// hex encoded SHA-256 of the body, or unsignedPayload.
+func (c *Client) authenticate(req *http.Request, contentHash string) {
+	if c.apiKey == "" {
+		req.Header.Set(authTokenHeader, c.authToken)
+		return
+	}
+
+	req.Header.Set(apiKeyHeader, c.apiKey)
+	if c.apiSecret == "" {
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(contentSHA256Header, contentHash)
+	req.Header.Set(signatureHeader, sign(c.apiSecret, req.Method, req.URL.RequestURI(), timestamp, contentHash))
+}
+
+// sign returns the hex encoded HMAC-SHA256, keyed with secret, of the
+// newline separated method, request URI, timestamp and content hash.
+func sign(secret, method, uri, timestamp, contentHash string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + contentHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,148 @@
+package apiclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// signedRequest is what the server received, with the signature it expects.
+type signedRequest struct {
+	header    http.Header
+	body      string
+	bodyHash  string
+	signature string
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(c *Client)
+		method string
+		body   interface{}
+		// failures is the number of attempts answered with a 503 first.
+		failures int
+		check    func(t *testing.T, req signedRequest)
+	}{
+		{
+			name:   "session token",
+			setup:  func(c *Client) { c.SetAuthToken("sess_abc") },
+			method: "GET",
+			check: func(t *testing.T, req signedRequest) {
+				if req.header.Get(authTokenHeader) != "sess_abc" {
+					t.Errorf("%s = %q", authTokenHeader, req.header.Get(authTokenHeader))
+				}
+				if req.header.Get(apiKeyHeader) != "" || req.header.Get(signatureHeader) != "" {
+					t.Errorf("API key headers sent with a session token: %v", req.header)
+				}
+			},
+		},
+		{
+			name:   "unsigned api key",
+			setup:  func(c *Client) { c.SetAPIKey("key_abc", "") },
+			method: "GET",
+			check: func(t *testing.T, req signedRequest) {
+				if req.header.Get(apiKeyHeader) != "key_abc" {
+					t.Errorf("%s = %q", apiKeyHeader, req.header.Get(apiKeyHeader))
+				}
+				for _, h := range []string{authTokenHeader, timestampHeader, contentSHA256Header, signatureHeader} {
+					if req.header.Get(h) != "" {
+						t.Errorf("%s sent for an unsigned API key", h)
+					}
+				}
+			},
+		},
+		{
+			name:   "signed get",
+			setup:  func(c *Client) { c.SetAPIKey("key_abc", "secret") },
+			method: "GET",
+			check:  checkSignature,
+		},
+		{
+			name:     "signed body replayed on retry",
+			setup:    func(c *Client) { c.SetAPIKey("key_abc", "secret") },
+			method:   "PUT",
+			body:     map[string]string{"name": "toaster"},
+			failures: 2,
+			check:    checkSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var received []signedRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				sum := sha256.Sum256(b)
+
+				mac := hmac.New(sha256.New, []byte("secret"))
+				mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + r.Header.Get(timestampHeader) + "\n" + r.Header.Get(contentSHA256Header)))
+
+				mu.Lock()
+				received = append(received, signedRequest{
+					header:    r.Header,
+					body:      string(b),
+					bodyHash:  hex.EncodeToString(sum[:]),
+					signature: hex.EncodeToString(mac.Sum(nil)),
+				})
+				fail := len(received) <= tt.failures
+				mu.Unlock()
+
+				if fail {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				io.WriteString(w, `{"success":true}`)
+			}))
+			defer srv.Close()
+
+			retry := DefaultRetryPolicy()
+			retry.MinBackoff, retry.MaxBackoff = time.Millisecond, time.Millisecond
+			c := NewClient(srv.URL, "v1").SetRetryPolicy(retry)
+			tt.setup(c)
+
+			var resp struct{}
+			_, err := c.request(context.Background(), true, "/toaster/t_abc?limit=1", tt.method, tt.body, &resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(received) != tt.failures+1 {
+				t.Fatalf("%d attempts, want %d", len(received), tt.failures+1)
+			}
+			for _, req := range received {
+				tt.check(t, req)
+				if req.body != received[0].body {
+					t.Errorf("body of a retry = %q, want %q", req.body, received[0].body)
+				}
+			}
+		})
+	}
+}
+
+// checkSignature recomputes the signature of the canonical request and
+// compares it, and the content hash, to the headers.
+func checkSignature(t *testing.T, req signedRequest) {
+	t.Helper()
+
+	if req.header.Get(apiKeyHeader) != "key_abc" {
+		t.Errorf("%s = %q", apiKeyHeader, req.header.Get(apiKeyHeader))
+	}
+	if req.header.Get(timestampHeader) == "" {
+		t.Errorf("no %s", timestampHeader)
+	}
+	if got := req.header.Get(contentSHA256Header); got != req.bodyHash {
+		t.Errorf("%s = %q, want the hash of %q, %q", contentSHA256Header, got, req.body, req.bodyHash)
+	}
+	if got := req.header.Get(signatureHeader); got != req.signature {
+		t.Errorf("%s = %q, want %q", signatureHeader, got, req.signature)
+	}
+}
@@ -3,46 +3,134 @@ package apiclient
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
 type Client struct {
-	domainName string
+	baseURL    string
 	apiVersion string
+	userAgent  string
+
+	// err is the configuration error of the client, returned by every API
+	// call.
+	err error
 
 	authToken string
+	apiKey    string
+	apiSecret string
+
+	http          *http.Client
+	middlewares   []Middleware
+	timeout       time.Duration
+	uploadTimeout time.Duration
+	retry         *RetryPolicy
+	limiter       *RateLimiter
 
-	http *http.Client
+	rateLimitMu sync.Mutex
+	rateLimit   RateLimit
+
+	logger    Logger
+	logBodies bool
+	logCurl   bool
 }
 
-func NewClient(domainName, apiVersion string) *Client {
-	if apiVersion == "" {
-		panic(fmt.Errorf("api version cannot be empty"))
+// Middleware wraps the transport used for every request made by the client.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// NewClient returns a client for the API served at baseURL. When baseURL has
+// no scheme, https is used. An invalid baseURL or apiVersion does not fail
+// here, it is reported by Err and by every API call.
+func NewClient(baseURL, apiVersion string) *Client {
+	c := &Client{
+		apiVersion: apiVersion,
+		http:       &http.Client{},
+		timeout:    30 * time.Second,
+		// Uploads can take a lot longer than regular API calls.
+		uploadTimeout: 3600 * time.Hour,
+		retry:         DefaultRetryPolicy(),
 	}
-	if domainName == "" {
-		panic(fmt.Errorf("api domain name cannot be empty"))
+
+	c.baseURL, c.err = ParseBaseURL(baseURL)
+	if c.err == nil && apiVersion == "" {
+		c.err = fmt.Errorf("api version cannot be empty")
 	}
+	return c
+}
 
-	return &Client{
-		domainName: domainName,
-		apiVersion: apiVersion,
-		http: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+// ParseBaseURL checks the URL of the API and returns it without its trailing
+// slash. When baseURL has no scheme, https is used.
+func ParseBaseURL(baseURL string) (string, error) {
+	if baseURL == "" {
+		return "", fmt.Errorf("api base url cannot be empty")
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid api base url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid api base url scheme %q", u.Scheme)
 	}
+	if u.Host == "" {
+		return "", fmt.Errorf("api base url %q has no host", baseURL)
+	}
+
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// Err returns the configuration error of the client, if any.
+func (c *Client) Err() error {
+	return c.err
 }
 
-func (c *Client) setupRequest(req *http.Request, authed bool) {
+func (c *Client) setupRequest(req *http.Request, authed bool, contentHash string) {
 	req.Header.Add("X-TOASTATE-APIVERSION", c.apiVersion)
 	req.Header.Add("Content-Type", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
 
 	if authed {
-		req.Header.Add("X-TOASTATE-AUTH", c.authToken)
+		c.authenticate(req, contentHash)
+	}
+}
+
+func (c *Client) SetUserAgent(userAgent string) *Client {
+	c.userAgent = userAgent
+	return c
+}
+
+// SetHTTPClient replaces the underlying HTTP client. Its timeout, when not
+// zero, is used for regular API calls until SetTimeout is called. A client
+// without a timeout keeps the default one.
+func (c *Client) SetHTTPClient(hc *http.Client) *Client {
+	c.http = hc
+	if hc.Timeout > 0 {
+		c.timeout = hc.Timeout
 	}
+	return c
 }
 
-func (c *Client) SetAuthToken(token string) *Client {
-	c.authToken = token
+// Use appends middlewares to the chain. The first middleware of the chain is
+// the outermost one, it sees requests first and responses last.
+func (c *Client) Use(middlewares ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+func (c *Client) SetTimeout(timeout time.Duration) *Client {
+	c.timeout = timeout
+	return c
+}
+
+func (c *Client) SetUploadTimeout(timeout time.Duration) *Client {
+	c.uploadTimeout = timeout
 	return c
 }
 
@@ -53,6 +141,61 @@ func (c *Client) prepareURL(url string) string {
 	if url[0] != '/' {
 		url = "/" + url
 	}
-	url = "https://" + c.domainName + url
+	url = c.baseURL + url
 	return url
 }
+
+// httpClient returns the HTTP client to use for a request, with the
+// middleware chain wrapped around the transport of the configured client.
+func (c *Client) httpClient(long bool) *http.Client {
+	hc := *c.http
+	hc.Timeout = c.timeout
+	if long {
+		hc.Timeout = c.uploadTimeout
+	}
+
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	hc.Transport = rt
+
+	return &hc
+}
+
+// Do sends req as is, outside of the API, through the transport and
+// middlewares of the client. Only the user agent is added, and the
+// authentication headers when authed is set. req is never retried.
+func (c *Client) Do(req *http.Request, authed bool) (*http.Response, error) {
+	c.prepareRawRequest(req, authed)
+
+	// Executions can run for as long as uploads do, ctx is the way to bound
+	// them.
+	start := time.Now()
+	response, err := c.httpClient(true).Do(req)
+	c.logRequest(req, nil, response, err, start, false)
+	return response, err
+}
+
+// RoundTrip is the http.RoundTripper counterpart of Do: redirects are not
+// followed and no timeout is applied.
+func (c *Client) RoundTrip(req *http.Request, authed bool) (*http.Response, error) {
+	c.prepareRawRequest(req, authed)
+
+	start := time.Now()
+	response, err := c.httpClient(true).Transport.RoundTrip(req)
+	c.logRequest(req, nil, response, err, start, false)
+	return response, err
+}
+
+func (c *Client) prepareRawRequest(req *http.Request, authed bool) {
+	if c.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if authed {
+		c.authenticate(req, unsignedPayload)
+	}
+}
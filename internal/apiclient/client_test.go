@@ -0,0 +1,27 @@
+package apiclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetHTTPClientTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"default kept", 0, 30 * time.Second},
+		{"client timeout", time.Second, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("https://api.test", "v1").SetHTTPClient(&http.Client{Timeout: tt.timeout})
+			if got := c.httpClient(false).Timeout; got != tt.want {
+				t.Errorf("timeout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
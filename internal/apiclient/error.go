@@ -1,9 +1,22 @@
 package apiclient
 
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const requestIDHeader = "X-Request-Id"
+
 type Error struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Status  int
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Status    int    `json:"-"`
+	RequestID string `json:"-"`
+	Body      []byte `json:"-"`
+
+	// RetryAfter is how long the API asked to wait before trying again.
+	RetryAfter time.Duration `json:"-"`
 }
 
 func NewError(status int, code, message string) *Error {
@@ -13,3 +26,26 @@ func NewError(status int, code, message string) *Error {
 		Status:  status,
 	}
 }
+
+func newResponseError(response *http.Response, b []byte) *Error {
+	e := &Error{
+		Status:     response.StatusCode,
+		RequestID:  response.Header.Get(requestIDHeader),
+		Body:       b,
+		RetryAfter: parseRetryAfter(response.Header, time.Now()),
+	}
+	if len(b) == 0 {
+		e.Code = "unhandled"
+		e.Message = "The remote API did not provide any error message"
+		return e
+	}
+
+	err := json.Unmarshal(b, e)
+	if err != nil {
+		e.Code = "unhandled"
+		e.Message = "The remote API provided the following invalid JSON error: " + string(b)
+		return e
+	}
+
+	return e
+}
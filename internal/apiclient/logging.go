@@ -0,0 +1,173 @@
+package apiclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Logger receives the debug output of the client. Arguments are alternating
+// keys and values, like log/slog: a *slog.Logger can be used as is.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+const redacted = "REDACTED"
+
+// Headers and JSON body fields whose values are never logged.
+var (
+	secretHeaders = []string{
+		authTokenHeader,
+		apiKeyHeader,
+		signatureHeader,
+		"Authorization",
+		"Cookie",
+		"Set-Cookie",
+	}
+	secretFields = map[string]bool{
+		"password":         true,
+		"token":            true,
+		"git_access_token": true,
+		"git_password":     true,
+		"api_secret":       true,
+	}
+)
+
+// SetLogger makes the client log every request to l. When bodies is true,
+// JSON bodies are logged too, and when curl is true every request is also
+// logged as an equivalent curl command. Secrets are redacted in all cases.
+func (c *Client) SetLogger(l Logger, bodies, curl bool) *Client {
+	c.logger = l
+	c.logBodies = bodies
+	c.logCurl = curl
+	return c
+}
+
+// logRequest logs a request once its response is received. The JSON response
+// body is only read and logged when readBody is set: requests sent outside
+// of the API and streamed responses go to the caller untouched.
+func (c *Client) logRequest(req *http.Request, p *payload, response *http.Response, err error, start time.Time, readBody bool) {
+	if c.logger == nil {
+		return
+	}
+
+	args := []interface{}{
+		"method", req.Method,
+		"url", req.URL.String(),
+		"latency", time.Since(start),
+	}
+
+	if c.logBodies && p != nil {
+		args = append(args, "request_body", redactBody(p.body))
+	}
+
+	if err != nil {
+		args = append(args, "error", err.Error())
+		c.logger.Debug("toastate api request failed", args...)
+	} else {
+		args = append(args, "status", response.StatusCode)
+		if id := response.Header.Get(requestIDHeader); id != "" {
+			args = append(args, "request_id", id)
+		}
+		if c.logBodies && readBody && strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+			b, _ := io.ReadAll(response.Body)
+			response.Body.Close()
+			response.Body = io.NopCloser(bytes.NewReader(b))
+			args = append(args, "response_body", redactBody(b))
+		}
+		c.logger.Debug("toastate api request", args...)
+	}
+
+	if c.logCurl {
+		c.logger.Debug("toastate api request as curl", "curl", curlCommand(req, p))
+	}
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range secretHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, redacted)
+		}
+	}
+	return h
+}
+
+// redactBody returns b with the values of secret JSON fields replaced. Bodies
+// that are not valid JSON are not logged.
+func redactBody(b []byte) string {
+	if b == nil {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "<non JSON body of " + strconv.Itoa(len(b)) + " bytes>"
+	}
+
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return "<unloggable body>"
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			if secretFields[strings.ToLower(k)] {
+				if s, ok := sub.(string); !ok || s != "" {
+					v[k] = redacted
+				}
+				continue
+			}
+			v[k] = redactValue(sub)
+		}
+	case []interface{}:
+		for i, sub := range v {
+			v[i] = redactValue(sub)
+		}
+	}
+	return v
+}
+
+// curlCommand returns a curl command equivalent to req, secrets redacted.
+func curlCommand(req *http.Request, p *payload) string {
+	var b strings.Builder
+	b.WriteString("curl -X " + req.Method + " " + shellQuote(req.URL.String()))
+
+	h := redactHeader(req.Header)
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			b.WriteString(" -H " + shellQuote(k+": "+v))
+		}
+	}
+
+	if p != nil {
+		if p.body != nil {
+			b.WriteString(" --data-raw " + shellQuote(redactBody(p.body)))
+		} else {
+			b.WriteString(" # streamed multipart body omitted")
+		}
+	}
+
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,183 @@
+package apiclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger keeps the messages and arguments it is given.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) log(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.log(msg, args...) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.log(msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.log(msg, args...) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) { l.log(msg, args...) }
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.entries, "\n")
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "password",
+			body: `{"email":"a@b.c","password":"hunter2"}`,
+			want: `{"email":"a@b.c","password":"REDACTED"}`,
+		},
+		{
+			name: "case insensitive",
+			body: `{"Token":"sess_abc"}`,
+			want: `{"Token":"REDACTED"}`,
+		},
+		{
+			name: "nested git credentials",
+			body: `{"toaster":{"git_access_token":"ghp_x","git_password":"pw","git_url":"https://x"}}`,
+			want: `{"toaster":{"git_access_token":"REDACTED","git_password":"REDACTED","git_url":"https://x"}}`,
+		},
+		{
+			name: "in arrays",
+			body: `[{"api_secret":"s"},{"name":"n"}]`,
+			want: `[{"api_secret":"REDACTED"},{"name":"n"}]`,
+		},
+		{
+			name: "empty secret kept",
+			body: `{"password":""}`,
+			want: `{"password":""}`,
+		},
+		{
+			name: "non string secret",
+			body: `{"token":{"value":"x"}}`,
+			want: `{"token":"REDACTED"}`,
+		},
+		{
+			name: "not JSON",
+			body: `password=hunter2`,
+			want: `<non JSON body of 16 bytes>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody([]byte(tt.body))
+			if got != tt.want {
+				t.Errorf("redactBody(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurlCommandRedaction(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://api.test/user/signin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(authTokenHeader, "sess_secret")
+	req.Header.Set(apiKeyHeader, "ak_secret")
+	req.Header.Set(signatureHeader, "deadbeef")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Other", "visible")
+
+	p := &payload{body: []byte(`{"email":"a@b.c","password":"it's secret"}`)}
+	got := curlCommand(req, p)
+
+	for _, secret := range []string{"sess_secret", "ak_secret", "deadbeef", "Bearer secret", "it's secret"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("curl command leaks %q: %s", secret, got)
+		}
+	}
+	for _, want := range []string{"curl -X POST 'https://api.test/user/signin'", "'X-Other: visible'", `"email":"a@b.c"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("curl command lacks %q: %s", want, got)
+		}
+	}
+
+	// Streamed bodies are not logged.
+	got = curlCommand(req, &payload{})
+	if !strings.HasSuffix(got, "# streamed multipart body omitted") {
+		t.Errorf("curl command of a streamed body = %s", got)
+	}
+}
+
+func TestLogRequestRedaction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"success":true,"token":"sess_returned"}`)
+	}))
+	defer srv.Close()
+
+	l := &recordingLogger{}
+	c := NewClient(srv.URL, "v1").SetLogger(l, true, true).SetAuthToken("sess_secret")
+
+	var resp struct {
+		Success bool   `json:"success"`
+		Token   string `json:"token"`
+	}
+	_, err := c.AuthedPost(context.Background(), "/user/signin", map[string]string{"password": "hunter2"}, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token != "sess_returned" {
+		t.Errorf("response token = %q, the logged body must still reach the caller", resp.Token)
+	}
+
+	logs := l.String()
+	for _, secret := range []string{"sess_secret", "hunter2", "sess_returned"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("logs leak %q:\n%s", secret, logs)
+		}
+	}
+	if !strings.Contains(logs, "REDACTED") {
+		t.Errorf("logs lack redacted bodies:\n%s", logs)
+	}
+}
+
+func TestLogRequestRawResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"token":"sess_toaster"}`)
+	}))
+	defer srv.Close()
+
+	l := &recordingLogger{}
+	c := NewClient(srv.URL, "v1").SetLogger(l, true, false)
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := c.Do(req, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	// The body of requests sent outside of the API is left to the caller.
+	b, err := io.ReadAll(response.Body)
+	if err != nil || string(b) != `{"token":"sess_toaster"}` {
+		t.Errorf("response body = %q, %v", b, err)
+	}
+	if strings.Contains(l.String(), "response_body") {
+		t.Errorf("response body of a raw request was logged:\n%s", l)
+	}
+}
@@ -1,75 +1,90 @@
 package apiclient
 
 import (
+	"context"
 	"io"
-
-	"github.com/toastate/toastate-sdk-go/common/models"
 )
 
-func (c *Client) AuthedGet(url string, resp interface{}) (*Error, error) {
-	return c.request(true, url, "GET", nil, resp)
+func (c *Client) AuthedGet(ctx context.Context, url string, resp interface{}) (*Error, error) {
+	return c.request(ctx, true, url, "GET", nil, resp)
+}
+
+func (c *Client) AuthedStreamedGet(ctx context.Context, url string) (io.ReadCloser, *Error, error) {
+	return c.requestStreamRawResponse(ctx, true, url, "GET", nil)
+}
+
+func (c *Client) Get(ctx context.Context, url string, resp interface{}) (*Error, error) {
+	return c.request(ctx, false, url, "GET", nil, resp)
+}
+
+func (c *Client) AuthedPost(ctx context.Context, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.request(ctx, true, url, "POST", body, resp)
+}
+
+func (c *Client) Post(ctx context.Context, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.request(ctx, false, url, "POST", body, resp)
 }
 
-func (c *Client) AuthedStreamedGet(url string) (io.ReadCloser, *Error, error) {
-	return c.requestStreamRawResponse(true, url, "GET", nil)
+func (c *Client) AuthedDelete(ctx context.Context, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.request(ctx, true, url, "DELETE", body, resp)
 }
 
-func (c *Client) Get(url string, resp interface{}) (*Error, error) {
-	return c.request(false, url, "GET", nil, resp)
+func (c *Client) Delete(ctx context.Context, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.request(ctx, false, url, "DELETE", body, resp)
 }
 
-func (c *Client) AuthedPost(url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.request(true, url, "POST", body, resp)
+func (c *Client) AuthedPut(ctx context.Context, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.request(ctx, true, url, "PUT", body, resp)
 }
 
-func (c *Client) Post(url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.request(false, url, "POST", body, resp)
+func (c *Client) Put(ctx context.Context, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.request(ctx, false, url, "PUT", body, resp)
 }
 
-func (c *Client) AuthedDelete(url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.request(true, url, "DELETE", body, resp)
+func (c *Client) AuthedMultipartFolderPost(ctx context.Context, folder *Folder, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartFolder(ctx, true, folder, url, "POST", body, resp)
 }
 
-func (c *Client) Delete(url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.request(false, url, "DELETE", body, resp)
+func (c *Client) MultipartFolderPost(ctx context.Context, folder *Folder, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartFolder(ctx, false, folder, url, "POST", body, resp)
 }
 
-func (c *Client) AuthedPut(url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.request(true, url, "PUT", body, resp)
+func (c *Client) AuthedMultipartReadersPost(ctx context.Context, stream *Stream, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartReaders(ctx, true, stream, url, "POST", body, resp)
 }
 
-func (c *Client) Put(url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.request(false, url, "PUT", body, resp)
+func (c *Client) MultipartReadersPost(ctx context.Context, stream *Stream, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartReaders(ctx, false, stream, url, "POST", body, resp)
 }
 
-func (c *Client) AuthedMultipartFolderPost(folder string, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartFolder(true, folder, url, "POST", body, resp)
+func (c *Client) AuthedMultipartFolderPut(ctx context.Context, folder *Folder, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartFolder(ctx, true, folder, url, "PUT", body, resp)
 }
 
-func (c *Client) MultipartFolderPost(folder string, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartFolder(false, folder, url, "POST", body, resp)
+func (c *Client) MultipartFolderPut(ctx context.Context, folder *Folder, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartFolder(ctx, false, folder, url, "PUT", body, resp)
 }
 
-func (c *Client) AuthedMultipartReadersPost(ch chan *models.MultipartItem, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartReaders(true, ch, url, "POST", body, resp)
+func (c *Client) AuthedMultipartReadersPut(ctx context.Context, stream *Stream, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartReaders(ctx, true, stream, url, "PUT", body, resp)
 }
 
-func (c *Client) MultipartReadersPost(ch chan *models.MultipartItem, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartReaders(false, ch, url, "POST", body, resp)
+func (c *Client) MultipartReadersPut(ctx context.Context, stream *Stream, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestMultipartReaders(ctx, false, stream, url, "PUT", body, resp)
 }
 
-func (c *Client) AuthedMultipartFolderPut(folder string, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartFolder(true, folder, url, "PUT", body, resp)
+func (c *Client) AuthedArchivePost(ctx context.Context, archive *Archive, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestArchive(ctx, true, archive, url, "POST", body, resp)
 }
 
-func (c *Client) MultipartFolderPut(folder string, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartFolder(false, folder, url, "PUT", body, resp)
+func (c *Client) ArchivePost(ctx context.Context, archive *Archive, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestArchive(ctx, false, archive, url, "POST", body, resp)
 }
 
-func (c *Client) AuthedMultipartReadersPut(ch chan *models.MultipartItem, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartReaders(true, ch, url, "PUT", body, resp)
+func (c *Client) AuthedArchivePut(ctx context.Context, archive *Archive, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestArchive(ctx, true, archive, url, "PUT", body, resp)
 }
 
-func (c *Client) MultipartReadersPut(ch chan *models.MultipartItem, url string, body interface{}, resp interface{}) (*Error, error) {
-	return c.requestMultipartReaders(false, ch, url, "PUT", body, resp)
+func (c *Client) ArchivePut(ctx context.Context, archive *Archive, url string, body interface{}, resp interface{}) (*Error, error) {
+	return c.requestArchive(ctx, false, archive, url, "PUT", body, resp)
 }
@@ -0,0 +1,111 @@
+package apiclient
+
+import (
+	"io"
+	"time"
+)
+
+// progressInterval is the minimum delay between two reports of the bytes
+// sent for the same file.
+const progressInterval = 100 * time.Millisecond
+
+// UploadProgress reports how far an upload of code is.
+type UploadProgress struct {
+	// File is the file being sent, empty for prebuilt archives.
+	File string
+	// Files is the number of files sent so far.
+	Files int
+
+	// BytesSent counts the content of the files, before any compression.
+	BytesSent int64
+	// TotalBytes is 0 when unknown, as for streams.
+	TotalBytes int64
+
+	Elapsed time.Duration
+	// BytesPerSecond is the average throughput since the upload started.
+	BytesPerSecond float64
+
+	// Done is set on the last report, once everything was sent.
+	Done bool
+}
+
+// ProgressFunc receives the progress of an upload. It is called from the
+// goroutine producing the upload, which it blocks.
+type ProgressFunc func(p UploadProgress)
+
+// progressTracker reports the progress of a single attempt of an upload.
+// Its methods do nothing on a nil tracker.
+type progressTracker struct {
+	fn    ProgressFunc
+	start time.Time
+	last  time.Time
+	p     UploadProgress
+}
+
+func newProgressTracker(fn ProgressFunc, total int64) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{
+		fn:    fn,
+		start: time.Now(),
+		p:     UploadProgress{TotalBytes: total},
+	}
+}
+
+func (t *progressTracker) report() {
+	now := time.Now()
+	t.last = now
+	t.p.Elapsed = now.Sub(t.start)
+	if secs := t.p.Elapsed.Seconds(); secs > 0 {
+		t.p.BytesPerSecond = float64(t.p.BytesSent) / secs
+	}
+	t.fn(t.p)
+}
+
+func (t *progressTracker) startFile(name string) {
+	if t == nil {
+		return
+	}
+	t.p.File = name
+	t.report()
+}
+
+func (t *progressTracker) endFile() {
+	if t == nil {
+		return
+	}
+	t.p.Files++
+	t.report()
+}
+
+func (t *progressTracker) done() {
+	if t == nil {
+		return
+	}
+	t.p.File = ""
+	t.p.Done = true
+	t.report()
+}
+
+// reader counts the bytes read from r as sent.
+func (t *progressTracker) reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &progressReader{r: r, t: t}
+}
+
+type progressReader struct {
+	r io.Reader
+	t *progressTracker
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.t.p.BytesSent += int64(n)
+	if n > 0 && time.Since(r.t.last) >= progressInterval {
+		r.t.report()
+	}
+	return n, err
+}
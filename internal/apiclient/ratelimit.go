@@ -0,0 +1,159 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+)
+
+// RateLimit is the API quota, as reported by the headers of the last
+// response that carried them.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+
+	// Known is false until a response reported the quota.
+	Known bool
+}
+
+func parseRateLimit(h http.Header, now time.Time) (RateLimit, bool) {
+	remaining, err := strconv.Atoi(strings.TrimSpace(h.Get(rateLimitRemainingHeader)))
+	if err != nil {
+		return RateLimit{}, false
+	}
+
+	rl := RateLimit{
+		Remaining: remaining,
+		Known:     true,
+	}
+	rl.Limit, _ = strconv.Atoi(strings.TrimSpace(h.Get(rateLimitLimitHeader)))
+
+	// The reset is either a unix timestamp or a number of seconds.
+	reset, err := strconv.ParseInt(strings.TrimSpace(h.Get(rateLimitResetHeader)), 10, 64)
+	if err == nil {
+		if reset > 1e9 {
+			rl.Reset = time.Unix(reset, 0)
+		} else {
+			rl.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	return rl, true
+}
+
+// parseRetryAfter reads the Retry-After header, given either in seconds or
+// as an HTTP date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	v := strings.TrimSpace(h.Get(retryAfterHeader))
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+func (c *Client) recordRateLimit(h http.Header) {
+	rl, ok := parseRateLimit(h, time.Now())
+	if !ok {
+		return
+	}
+
+	c.rateLimitMu.Lock()
+	c.rateLimit = rl
+	c.rateLimitMu.Unlock()
+}
+
+func (c *Client) RateLimit() RateLimit {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+
+	return c.rateLimit
+}
+
+// RateLimiter is a token bucket limiting the rate of requests. A single
+// limiter can be shared by several clients and goroutines.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// minRate is the lowest rate of a RateLimiter, one request per hour.
+const minRate = 1.0 / 3600
+
+// NewRateLimiter allows rate requests per second on average, with bursts of
+// up to burst requests. A rate below minRate, zero or negative included, is
+// raised to it.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	// Also catches NaN.
+	if !(rate >= minRate) {
+		rate = minRate
+	}
+
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request can be sent, or until ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Take the token right away, possibly going below zero: the deficit is
+	// the time this caller has to wait, and later callers queue behind it.
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := SleepContext(ctx, wait); err != nil {
+		// Give the token back since no request will be sent.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (c *Client) SetRateLimiter(l *RateLimiter) *Client {
+	c.limiter = l
+	return c
+}
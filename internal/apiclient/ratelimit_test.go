@@ -0,0 +1,173 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"padded seconds", " 3 ", 3 * time.Second},
+		{"zero", "0", 0},
+		{"negative", "-5", 0},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"past http date", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"invalid", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set(retryAfterHeader, tt.value)
+			}
+
+			got := parseRetryAfter(h, now)
+			if got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   RateLimit
+		ok     bool
+	}{
+		{
+			name:   "missing",
+			header: map[string]string{},
+		},
+		{
+			name:   "invalid remaining",
+			header: map[string]string{rateLimitRemainingHeader: "many"},
+		},
+		{
+			name: "reset in seconds",
+			header: map[string]string{
+				rateLimitLimitHeader:     "100",
+				rateLimitRemainingHeader: "42",
+				rateLimitResetHeader:     "30",
+			},
+			want: RateLimit{Limit: 100, Remaining: 42, Reset: now.Add(30 * time.Second), Known: true},
+			ok:   true,
+		},
+		{
+			name: "reset as unix timestamp",
+			header: map[string]string{
+				rateLimitLimitHeader:     "100",
+				rateLimitRemainingHeader: "0",
+				rateLimitResetHeader:     "1714568400",
+			},
+			want: RateLimit{Limit: 100, Remaining: 0, Reset: time.Unix(1714568400, 0), Known: true},
+			ok:   true,
+		},
+		{
+			name:   "remaining only",
+			header: map[string]string{rateLimitRemainingHeader: "7"},
+			want:   RateLimit{Remaining: 7, Known: true},
+			ok:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+
+			got, ok := parseRateLimit(h, now)
+			if ok != tt.ok {
+				t.Fatalf("parseRateLimit ok = %v, want %v", ok, tt.ok)
+			}
+			if got.Limit != tt.want.Limit || got.Remaining != tt.want.Remaining || !got.Reset.Equal(tt.want.Reset) || got.Known != tt.want.Known {
+				t.Errorf("parseRateLimit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(20, 3)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("burst of 3 took %v, want no wait", d)
+	}
+
+	// The bucket is empty: the next request waits for a token, 50ms at 20
+	// requests per second.
+	start = time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("request after the burst waited %v, want about 50ms", d)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := l.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The token of the cancelled request was given back.
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.5 {
+		t.Errorf("tokens = %v after a cancelled wait, want the token back", tokens)
+	}
+}
+
+func TestRateLimiterInvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		l := NewRateLimiter(rate, 1)
+		if l.rate != minRate {
+			t.Errorf("NewRateLimiter(%v) rate = %v, want %v", rate, l.rate, minRate)
+		}
+
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// Once the burst is used, the limiter keeps limiting.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := l.Wait(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("NewRateLimiter(%v): second Wait = %v, want %v", rate, err, context.DeadlineExceeded)
+		}
+	}
+}
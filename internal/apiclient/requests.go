@@ -3,275 +3,386 @@ package apiclient
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base32"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"io/fs"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/internal/ignore"
 )
 
-func (c *Client) request(authed bool, url, method string, body interface{}, resp interface{}) (*Error, error) {
-	url = c.prepareURL(url)
+// payload is the body of a single attempt of a request.
+type payload struct {
+	// r is a *bytes.Reader when the body is in memory, so that net/http
+	// sends its Content-Length and can rewind it.
+	r           io.Reader
+	contentType string
 
-	var req *http.Request
-	var err error
-	if body != nil {
-		b := new(bytes.Buffer)
-		enc := json.NewEncoder(b)
-		enc.SetEscapeHTML(false)
-		err = enc.Encode(body)
-		if err != nil {
-			return nil, err
-		}
+	// sha256 is the hex encoded hash of the content of r, or unsignedPayload
+	// when it is streamed.
+	sha256 string
 
-		req, err = http.NewRequest(method, url, b)
-	} else {
-		req, err = http.NewRequest(method, url, nil)
-	}
+	// body is the content of r when it is not streamed.
+	body []byte
+
+	// wait, when set, unblocks the goroutine producing r if it is still
+	// writing, waits for it to be done and returns the first error it hit.
+	wait func() error
+}
+
+// payloadFunc builds a fresh payload for every attempt of a request.
+type payloadFunc func(ctx context.Context) (*payload, error)
+
+type call struct {
+	authed  bool
+	method  string
+	url     string
+	payload payloadFunc
+
+	// replayable is false when payload can only be called once, in which
+	// case the request is never retried.
+	replayable bool
+
+	// long requests, uploads and streamed responses, are not bound by the
+	// timeout of regular API calls.
+	long bool
+
+	// rawResponse is set when the response body is handed to the caller as
+	// is, so that it is not read for logging.
+	rawResponse bool
+}
+
+func (c *Client) request(ctx context.Context, authed bool, url, method string, body interface{}, resp interface{}) (*Error, error) {
+	payload, err := jsonPayload(body)
 	if err != nil {
 		return nil, err
 	}
 
-	c.setupRequest(req, authed)
+	return c.doJSON(ctx, &call{
+		authed:     authed,
+		method:     method,
+		url:        url,
+		payload:    payload,
+		replayable: true,
+	}, resp)
+}
 
-	response, err := c.http.Do(req)
+func (c *Client) requestStreamRawResponse(ctx context.Context, authed bool, url, method string, body interface{}) (io.ReadCloser, *Error, error) {
+	payload, err := jsonPayload(body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	b, _ := io.ReadAll(response.Body)
-	response.Body.Close()
+	response, apierr, err := c.do(ctx, &call{
+		authed:      authed,
+		method:      method,
+		url:         url,
+		payload:     payload,
+		replayable:  true,
+		long:        true,
+		rawResponse: true,
+	})
+	if err != nil || apierr != nil {
+		return nil, apierr, err
+	}
 
-	if response.StatusCode != 200 {
-		e := &Error{
-			Status: response.StatusCode,
-		}
-		if len(b) == 0 {
-			e.Code = "unhandled"
-			e.Message = "The remote API did not provide any error message"
-			return e, nil
-		}
+	return response.Body, nil, nil
+}
+
+// Folder is a folder of code to upload.
+type Folder struct {
+	FS     fs.FS
+	Ignore ignore.Options
+
+	// Archive, when set, packs the files in a single archive part instead
+	// of sending a part per file.
+	Archive ArchiveFormat
+
+	// Filter, when set, only keeps the files it returns true for.
+	Filter func(name string) bool
+
+	// Progress, when set, is reported to with the total size of the files,
+	// which are listed before the upload starts.
+	Progress ProgressFunc
+}
+
+// Stream is code to upload whose files are received from a channel, until
+// a nil item.
+type Stream struct {
+	Items chan *models.MultipartItem
+
+	// Archive, when set, packs the files in a single archive part instead
+	// of sending a part per file.
+	Archive ArchiveFormat
+
+	Progress ProgressFunc
+}
 
-		err = json.Unmarshal(b, e)
+// Archive is a prebuilt archive of code to upload.
+type Archive struct {
+	// Open returns the content of the archive. When Replayable, it is
+	// called again on every attempt, so the upload can be retried.
+	Open       func() (io.ReadCloser, error)
+	Replayable bool
+
+	Format ArchiveFormat
+	// Size is 0 when unknown.
+	Size int64
+
+	Progress ProgressFunc
+}
+
+func (c *Client) requestMultipartFolder(ctx context.Context, authed bool, folder *Folder, url, method string, body interface{}, resp interface{}) (*Error, error) {
+	if folder.Archive != "" {
+		err := folder.Archive.validate()
 		if err != nil {
-			e.Code = "unhandled"
-			e.Message = "The remote API provided the following invalid JSON error: " + string(b)
-			return e, nil
+			return nil, err
 		}
-
-		return e, nil
 	}
 
-	err = json.Unmarshal(b, resp)
+	bod, err := marshalBody(body)
 	if err != nil {
 		return nil, err
 	}
 
-	return nil, nil
+	// The folder is walked again on every attempt, so the upload can be
+	// retried.
+	return c.doJSON(ctx, &call{
+		authed:     authed,
+		method:     method,
+		url:        url,
+		payload:    folderPayload(folder, bod),
+		replayable: true,
+		long:       true,
+	}, resp)
 }
 
-func (c *Client) requestStreamRawResponse(authed bool, url, method string, body interface{}) (io.ReadCloser, *Error, error) {
-	url = c.prepareURL(url)
-
-	var req *http.Request
-	var err error
-	if body != nil {
-		b := new(bytes.Buffer)
-		enc := json.NewEncoder(b)
-		enc.SetEscapeHTML(false)
-		err = enc.Encode(body)
+func (c *Client) requestMultipartReaders(ctx context.Context, authed bool, stream *Stream, url, method string, body interface{}, resp interface{}) (*Error, error) {
+	if stream.Archive != "" {
+		err := stream.Archive.validate()
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-
-		req, err = http.NewRequest(method, url, b)
-	} else {
-		req, err = http.NewRequest(method, url, nil)
 	}
+
+	bod, err := marshalBody(body)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	c.setupRequest(req, authed)
+	// Items consumed from ch are gone once sent, so this upload can not be
+	// retried.
+	return c.doJSON(ctx, &call{
+		authed:     authed,
+		method:     method,
+		url:        url,
+		payload:    readersPayload(stream, bod),
+		replayable: false,
+		long:       true,
+	}, resp)
+}
 
-	response, err := c.http.Do(req)
+func (c *Client) requestArchive(ctx context.Context, authed bool, archive *Archive, url, method string, body interface{}, resp interface{}) (*Error, error) {
+	err := archive.Format.validate()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	if response.StatusCode != 200 {
-		b, _ := io.ReadAll(response.Body)
-		response.Body.Close()
-
-		e := &Error{
-			Status: response.StatusCode,
-		}
-		if len(b) == 0 {
-			e.Code = "unhandled"
-			e.Message = "The remote API did not provide any error message"
-			return nil, e, nil
-		}
+	bod, err := marshalBody(body)
+	if err != nil {
+		return nil, err
+	}
 
-		err = json.Unmarshal(b, e)
-		if err != nil {
-			e.Code = "unhandled"
-			e.Message = "The remote API provided the following invalid JSON error: " + string(b)
-			return nil, e, nil
-		}
+	return c.doJSON(ctx, &call{
+		authed:     authed,
+		method:     method,
+		url:        url,
+		payload:    archivePayload(archive, bod),
+		replayable: archive.Replayable,
+		long:       true,
+	}, resp)
+}
 
-		return nil, e, nil
+// doJSON runs cl and decodes the JSON response into resp.
+func (c *Client) doJSON(ctx context.Context, cl *call, resp interface{}) (*Error, error) {
+	response, apierr, err := c.do(ctx, cl)
+	if err != nil || apierr != nil {
+		return apierr, err
 	}
 
-	return response.Body, nil, nil
-}
+	b, _ := io.ReadAll(response.Body)
+	response.Body.Close()
 
-func (c *Client) requestMultipartFolder(authed bool, folder, url, method string, body interface{}, resp interface{}) (*Error, error) {
-	url = c.prepareURL(url)
-	bod, err := marshalBody(body)
+	err = json.Unmarshal(b, resp)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a pipe for writing from the file and reading to
-	// the request concurrently.
-	bodyReader, bodyWriter := io.Pipe()
-	formWriter := multipart.NewWriter(bodyWriter)
+	return nil, nil
+}
 
-	// Store the first write error in writeErr.
-	var (
-		writeErr error
-		errOnce  sync.Once
-	)
-	setErr := func(err error) {
-		if err != nil {
-			errOnce.Do(func() { writeErr = err })
-		}
+// do runs cl, retrying it according to the client's retry policy. When no
+// error is returned, the caller must close the response body.
+func (c *Client) do(ctx context.Context, cl *call) (*http.Response, *Error, error) {
+	if c.err != nil {
+		return nil, nil, c.err
 	}
-	go func() {
-		err := filepath.Walk(folder, func(path string, info fs.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
 
-			if info.IsDir() {
-				return nil
-			}
+	url := c.prepareURL(cl.url)
 
-			rel, err := filepath.Rel(folder, path)
-			if err != nil {
-				return err
-			}
+	attempts := 1
+	if cl.replayable && c.retry.enabled() {
+		attempts = c.retry.MaxAttempts
+	}
 
-			partWriter, err := formWriter.CreateFormFile("file", base32.StdEncoding.EncodeToString([]byte(rel)))
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			err := c.limiter.Wait(ctx)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
+		}
 
-			f, err := os.OpenFile(path, os.O_RDONLY, 0644)
-			if err != nil {
-				return err
-			}
+		response, err := c.send(ctx, cl, url)
+		var perr *payloadError
+		if errors.As(err, &perr) {
+			// Only errors of the transport are worth retrying.
+			return nil, nil, perr.err
+		}
+		if err == nil {
+			c.recordRateLimit(response.Header)
+		}
 
-			// Reduce number of syscalls when reading from disk.
-			bufferedFileReader := bufio.NewReader(f)
-			defer f.Close()
+		if attempt < attempts {
+			wait, retry := c.retry.delay(cl.method, attempt, response, err)
+			if retry {
+				if c.logger != nil {
+					c.logger.Warn("toastate api request retried", "method", cl.method, "url", url, "attempt", attempt, "wait", wait)
+				}
+				if response != nil {
+					io.Copy(io.Discard, response.Body)
+					response.Body.Close()
+				}
 
-			for {
-				n, err := io.CopyN(partWriter, bufferedFileReader, 1024*1024*5)
+				err = SleepContext(ctx, wait)
 				if err != nil {
-					if err != io.EOF {
-						return err
-					}
-					break
-				}
-				if n == 0 {
-					break
+					return nil, nil, err
 				}
+				continue
 			}
+		}
 
-			return nil
-		})
-
-		setErr(err)
-
-		if err == nil && len(bod) > 0 {
-			err = formWriter.WriteField("request", string(bod))
-			if err != nil {
-				setErr(err)
-			}
+		if err != nil {
+			return nil, nil, err
 		}
 
-		setErr(formWriter.Close())
-		setErr(bodyWriter.Close())
-	}()
+		if response.StatusCode != 200 {
+			b, _ := io.ReadAll(response.Body)
+			response.Body.Close()
 
-	req, err := http.NewRequest(method, url, bodyReader)
-	if err != nil {
-		return nil, err
-	}
-	c.setupRequest(req, authed)
-	req.Header.Set("Content-Type", formWriter.FormDataContentType())
+			return nil, newResponseError(response, b), nil
+		}
 
-	// This operation will block until both the formWriter
-	// and bodyWriter have been closed by the goroutine,
-	// or in the event of a HTTP error.
-	response, err := (&http.Client{
-		Timeout: 3600 * time.Hour,
-	}).Do(req)
-	if err != nil {
-		return nil, err
+		return response, nil, nil
 	}
+}
 
-	if writeErr != nil {
-		return nil, writeErr
-	}
+// payloadError is an error of the payload of a request rather than of its
+// transport, e.g. a file of a code folder that can not be read. It is not
+// retried.
+type payloadError struct {
+	err error
+}
 
-	b, _ := io.ReadAll(response.Body)
-	response.Body.Close()
+func (e *payloadError) Error() string {
+	return e.err.Error()
+}
 
-	if response.StatusCode != 200 {
-		e := &Error{
-			Status: response.StatusCode,
-		}
-		if len(b) == 0 {
-			e.Code = "unhandled"
-			e.Message = "The remote API did not provide any error message"
-			return e, nil
-		}
+func (e *payloadError) Unwrap() error {
+	return e.err
+}
 
-		err = json.Unmarshal(b, e)
+// send performs a single attempt of cl. Errors that happen before the
+// request is sent or while its payload is produced are *payloadError.
+func (c *Client) send(ctx context.Context, cl *call, url string) (*http.Response, error) {
+	var body io.Reader
+	var p *payload
+	contentHash := emptySHA256
+	if cl.payload != nil {
+		var err error
+		p, err = cl.payload(ctx)
 		if err != nil {
-			e.Code = "unhandled"
-			e.Message = "The remote API provided the following invalid JSON error: " + string(b)
-			return e, nil
+			return nil, &payloadError{err}
 		}
-
-		return e, nil
+		body = p.r
+		contentHash = p.sha256
 	}
 
-	err = json.Unmarshal(b, resp)
+	req, err := http.NewRequestWithContext(ctx, cl.method, url, body)
 	if err != nil {
-		return nil, err
+		if p != nil && p.wait != nil {
+			p.wait()
+		}
+		return nil, &payloadError{err}
+	}
+	c.setupRequest(req, cl.authed, contentHash)
+	if p != nil && p.contentType != "" {
+		req.Header.Set("Content-Type", p.contentType)
 	}
 
-	return nil, nil
+	// For multipart payloads, this operation will block until the producer
+	// goroutine is done writing, or in the event of a HTTP error.
+	start := time.Now()
+	response, err := c.httpClient(cl.long).Do(req)
+	c.logRequest(req, p, response, err, start, !cl.rawResponse)
+
+	if p != nil && p.wait != nil {
+		// Unblock the producer if the request ended early (cancelled
+		// context, HTTP error) and wait for it.
+		writeErr := p.wait()
+
+		// A closed pipe only means the request ended first, err tells why.
+		if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+			if response != nil {
+				response.Body.Close()
+			}
+			return nil, &payloadError{writeErr}
+		}
+	}
+
+	return response, err
 }
 
-func (c *Client) requestMultipartReaders(authed bool, ch chan *models.MultipartItem, url, method string, body interface{}, resp interface{}) (*Error, error) {
-	url = c.prepareURL(url)
-	bod, err := marshalBody(body)
+func jsonPayload(body interface{}) (payloadFunc, error) {
+	if body == nil {
+		return nil, nil
+	}
+
+	b, err := marshalBody(body)
 	if err != nil {
 		return nil, err
 	}
 
+	sum := sha256Hex(b)
+	return func(ctx context.Context) (*payload, error) {
+		return &payload{
+			r:      bytes.NewReader(b),
+			sha256: sum,
+			body:   b,
+		}, nil
+	}, nil
+}
+
+// multipartPayload streams the parts written by produce through a pipe, so
+// that the request is sent while the parts are being produced.
+func multipartPayload(bod []byte, produce func(formWriter *multipart.Writer) error) *payload {
 	// Create a pipe for writing from the file and reading to
 	// the request concurrently.
 	bodyReader, bodyWriter := io.Pipe()
@@ -287,41 +398,12 @@ func (c *Client) requestMultipartReaders(authed bool, ch chan *models.MultipartI
 			errOnce.Do(func() { writeErr = err })
 		}
 	}
+	done := make(chan struct{})
 	go func() {
-		var err error
-		var partWriter io.Writer
-		var n int64
-	F1:
-		for {
-			item := <-ch
-			if item == nil {
-				break F1
-			}
-
-			partWriter, err = formWriter.CreateFormFile("file", base32.StdEncoding.EncodeToString([]byte(item.Filename)))
-			if err != nil {
-				item.R.Close()
-				setErr(err)
-				break F1
-			}
-
-			for {
-				n, err = io.CopyN(partWriter, item.R, 1024*1024*5)
-				if err != nil {
-					if err != io.EOF {
-						item.R.Close()
-						setErr(err)
-						break F1
-					}
-					break
-				}
-				if n == 0 {
-					break
-				}
-			}
+		defer close(done)
 
-			item.R.Close()
-		}
+		err := produce(formWriter)
+		setErr(err)
 
 		if err == nil && len(bod) > 0 {
 			err = formWriter.WriteField("request", string(bod))
@@ -331,58 +413,231 @@ func (c *Client) requestMultipartReaders(authed bool, ch chan *models.MultipartI
 		}
 
 		setErr(formWriter.Close())
-		setErr(bodyWriter.Close())
+		if writeErr != nil {
+			bodyWriter.CloseWithError(writeErr)
+		} else {
+			setErr(bodyWriter.Close())
+		}
 	}()
 
-	req, err := http.NewRequest(method, url, bodyReader)
+	return &payload{
+		r:           bodyReader,
+		contentType: formWriter.FormDataContentType(),
+		sha256:      unsignedPayload,
+		wait: func() error {
+			bodyReader.Close()
+			<-done
+			return writeErr
+		},
+	}
+}
+
+// codeWriter writes the files of the code of a toaster, either as a part
+// per file or in a single archive part.
+type codeWriter struct {
+	formWriter *multipart.Writer
+	archive    archiveWriter
+	progress   *progressTracker
+}
+
+func newCodeWriter(formWriter *multipart.Writer, format ArchiveFormat, progress *progressTracker) (*codeWriter, error) {
+	w := &codeWriter{formWriter: formWriter, progress: progress}
+	if format == "" {
+		return w, nil
+	}
+
+	partWriter, err := formWriter.CreateFormFile("archive", "code."+string(format))
 	if err != nil {
 		return nil, err
 	}
-	c.setupRequest(req, authed)
-	req.Header.Set("Content-Type", formWriter.FormDataContentType())
-
-	// This operation will block until both the formWriter
-	// and bodyWriter have been closed by the goroutine,
-	// or in the event of a HTTP error.
-	response, err := (&http.Client{
-		Timeout: 3600 * time.Hour,
-	}).Do(req)
+	w.archive, err = newArchiveWriter(format, partWriter)
 	if err != nil {
-		fmt.Println("3", err)
 		return nil, err
 	}
+	return w, nil
+}
+
+// add writes a file of size bytes read from r, size being -1 when unknown.
+func (w *codeWriter) add(name string, mode fs.FileMode, size int64, r io.Reader) error {
+	w.progress.startFile(name)
+	r = w.progress.reader(r)
 
-	if writeErr != nil {
-		return nil, writeErr
+	err := w.write(name, mode, size, r)
+	if err != nil {
+		return err
 	}
 
-	b, _ := io.ReadAll(response.Body)
-	response.Body.Close()
+	w.progress.endFile()
+	return nil
+}
+
+func (w *codeWriter) write(name string, mode fs.FileMode, size int64, r io.Reader) error {
+	if w.archive != nil {
+		return w.archive.add(name, mode, size, r)
+	}
+
+	partWriter, err := w.formWriter.CreateFormFile("file", base32.StdEncoding.EncodeToString([]byte(name)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		n, err := io.CopyN(partWriter, r, 1024*1024*5)
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	return nil
+}
 
-	if response.StatusCode != 200 {
-		e := &Error{
-			Status: response.StatusCode,
+func (w *codeWriter) Close() error {
+	if w.archive != nil {
+		err := w.archive.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	w.progress.done()
+	return nil
+}
+
+// walk calls fn with the files of folder to upload.
+func (folder *Folder) walk(fn func(name string) error) error {
+	return ignore.Walk(folder.FS, folder.Ignore, func(name string, d fs.DirEntry) error {
+		if folder.Filter != nil && !folder.Filter(name) {
+			return nil
 		}
-		if len(b) == 0 {
-			e.Code = "unhandled"
-			e.Message = "The remote API did not provide any error message"
-			return e, nil
+		return fn(name)
+	})
+}
+
+// size returns the total size of the files of folder to upload.
+func (folder *Folder) size(ctx context.Context) (int64, error) {
+	var total int64
+	err := folder.walk(func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		err = json.Unmarshal(b, e)
+		info, err := fs.Stat(folder.FS, name)
 		if err != nil {
-			e.Code = "unhandled"
-			e.Message = "The remote API provided the following invalid JSON error: " + string(b)
-			return e, nil
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+func folderPayload(folder *Folder, bod []byte) payloadFunc {
+	return func(ctx context.Context) (*payload, error) {
+		var progress *progressTracker
+		if folder.Progress != nil {
+			total, err := folder.size(ctx)
+			if err != nil {
+				return nil, err
+			}
+			progress = newProgressTracker(folder.Progress, total)
 		}
 
-		return e, nil
+		return multipartPayload(bod, func(formWriter *multipart.Writer) error {
+			cw, err := newCodeWriter(formWriter, folder.Archive, progress)
+			if err != nil {
+				return err
+			}
+
+			// Files are walked in lexical order, so that archives are
+			// deterministic.
+			err = folder.walk(func(name string) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				f, err := folder.FS.Open(name)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				info, err := f.Stat()
+				if err != nil {
+					return err
+				}
+
+				// Reduce number of syscalls when reading from disk.
+				return cw.add(name, info.Mode(), info.Size(), bufio.NewReader(f))
+			})
+			if err != nil {
+				return err
+			}
+
+			return cw.Close()
+		}), nil
 	}
+}
 
-	err = json.Unmarshal(b, resp)
-	if err != nil {
-		return nil, err
+func readersPayload(stream *Stream, bod []byte) payloadFunc {
+	return func(ctx context.Context) (*payload, error) {
+		progress := newProgressTracker(stream.Progress, 0)
+
+		return multipartPayload(bod, func(formWriter *multipart.Writer) error {
+			cw, err := newCodeWriter(formWriter, stream.Archive, progress)
+			if err != nil {
+				return err
+			}
+
+			for {
+				var item *models.MultipartItem
+				select {
+				case item = <-stream.Items:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if item == nil {
+					return cw.Close()
+				}
+
+				err := cw.add(item.Filename, 0644, -1, item.R)
+				item.R.Close()
+				if err != nil {
+					return err
+				}
+			}
+		}), nil
 	}
+}
 
-	return nil, nil
+func archivePayload(archive *Archive, bod []byte) payloadFunc {
+	return func(ctx context.Context) (*payload, error) {
+		r, err := archive.Open()
+		if err != nil {
+			return nil, err
+		}
+		progress := newProgressTracker(archive.Progress, archive.Size)
+
+		return multipartPayload(bod, func(formWriter *multipart.Writer) error {
+			defer r.Close()
+
+			partWriter, err := formWriter.CreateFormFile("archive", "code."+string(archive.Format))
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(partWriter, progress.reader(r))
+			if err != nil {
+				return err
+			}
+
+			progress.done()
+			return nil
+		}), nil
+	}
 }
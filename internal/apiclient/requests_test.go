@@ -0,0 +1,186 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// trackingFS counts the files of an fs.FS that are still open.
+type trackingFS struct {
+	fs.FS
+
+	mu   sync.Mutex
+	open int
+}
+
+func (fsys *trackingFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys.mu.Lock()
+	fsys.open++
+	fsys.mu.Unlock()
+	return &trackedFile{File: f, fsys: fsys}, nil
+}
+
+func (fsys *trackingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.FS, name)
+}
+
+func (fsys *trackingFS) openFiles() int {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.open
+}
+
+type trackedFile struct {
+	fs.File
+	fsys *trackingFS
+}
+
+func (f *trackedFile) Close() error {
+	f.fsys.mu.Lock()
+	f.fsys.open--
+	f.fsys.mu.Unlock()
+	return f.File.Close()
+}
+
+func TestMultipartFolderCancel(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Read the start of the upload, then stop reading so that the
+		// producer blocks until the request is cancelled.
+		// The server only notices the client going away once the body
+		// is read, so the handler is released by the test.
+		io.ReadFull(r.Body, make([]byte, 1024))
+		once.Do(func() { close(started) })
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	fsys := &trackingFS{FS: fstest.MapFS{
+		"big.bin":  &fstest.MapFile{Data: make([]byte, 16<<20)},
+		"main.go":  &fstest.MapFile{Data: []byte("package main")},
+		"other.go": &fstest.MapFile{Data: []byte("package main")},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	c := NewClient(srv.URL, "v1")
+	var resp struct{}
+	_, err := c.AuthedMultipartFolderPost(ctx, &Folder{FS: fsys}, "/toaster", nil, &resp)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("AuthedMultipartFolderPost = %v, want %v", err, context.Canceled)
+	}
+
+	// The producer closes the file it is sending when it returns, so an
+	// open file means it is still blocked on the pipe.
+	if n := fsys.openFiles(); n != 0 {
+		t.Errorf("%d files still open after the call returned", n)
+	}
+}
+
+func TestJSONContentLength(t *testing.T) {
+	var contentLength int64
+	var transferEncoding []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength, transferEncoding = r.ContentLength, r.TransferEncoding
+		io.WriteString(w, `{"success":true}`)
+	}))
+	defer srv.Close()
+
+	var getBody bool
+	c := NewClient(srv.URL, "v1").Use(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			getBody = req.GetBody != nil
+			return next.RoundTrip(req)
+		})
+	})
+
+	body := map[string]string{"email": "a@b.c"}
+	var resp struct{}
+	_, err := c.Post(context.Background(), "/signin", body, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := marshalBody(body)
+	if contentLength != int64(len(b)) || len(transferEncoding) != 0 {
+		t.Errorf("Content-Length = %d, Transfer-Encoding = %q, want a length of %d", contentLength, transferEncoding, len(b))
+	}
+	if !getBody {
+		t.Error("the request has no GetBody, it can not be rewound")
+	}
+}
+
+// brokenFS fails to open one of its files.
+type brokenFS struct {
+	fstest.MapFS
+}
+
+func (fsys brokenFS) Open(name string) (fs.File, error) {
+	if name == "broken.go" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func TestPayloadErrorNotRetried(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	fsys := brokenFS{fstest.MapFS{
+		"broken.go": &fstest.MapFile{Data: []byte("package main")},
+		"main.go":   &fstest.MapFile{Data: []byte("package main")},
+	}}
+
+	retry := DefaultRetryPolicy()
+	retry.MinBackoff, retry.MaxBackoff = time.Millisecond, time.Millisecond
+	c := NewClient(srv.URL, "v1").SetRetryPolicy(retry)
+
+	var resp struct{}
+	_, err := c.AuthedMultipartFolderPut(context.Background(), &Folder{FS: fsys}, "/toaster/t_x", nil, &resp)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("AuthedMultipartFolderPut = %v, want %v", err, fs.ErrPermission)
+	}
+	var perr *payloadError
+	if errors.As(err, &perr) {
+		t.Errorf("the payload error is returned wrapped: %#v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests > 1 {
+		t.Errorf("%d requests, want the upload not to be retried", requests)
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
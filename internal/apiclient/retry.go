@@ -0,0 +1,159 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried.
+//
+// Only idempotent requests (GET, HEAD, OPTIONS, PUT, DELETE) are retried,
+// unless RetryNonIdempotent is set. Throttled requests (429) are the
+// exception: they were not processed, so any method is retried, after the
+// delay given by the Retry-After header when there is one. Uploads streamed
+// from a channel are never retried since their content can not be replayed,
+// nor are local errors such as a file of a code folder that can not be read.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, the first one included.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+
+	// The backoff starts at MinBackoff and doubles after each attempt, up to
+	// MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of each backoff that is
+	// randomized.
+	Jitter float64
+
+	// RetryableStatuses lists the HTTP status codes that are retried.
+	RetryableStatuses []int
+
+	// RetryableError reports whether a transport error is retried. When nil,
+	// every error but context cancellations and deadlines is retried.
+	RetryableError func(err error) bool
+
+	// RetryNonIdempotent allows retrying POST requests. The request may then
+	// be applied more than once by the API.
+	RetryNonIdempotent bool
+
+	// MaxRetryAfter caps the delay honoured from a Retry-After header. When
+	// the API asks to wait longer, the 429 error is returned instead.
+	MaxRetryAfter time.Duration
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:   3,
+		MinBackoff:    200 * time.Millisecond,
+		MaxBackoff:    5 * time.Second,
+		Jitter:        0.5,
+		MaxRetryAfter: time.Minute,
+		RetryableStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+func (c *Client) SetRetryPolicy(p *RetryPolicy) *Client {
+	c.retry = p
+	return c
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+func (p *RetryPolicy) allows(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	default:
+		return p.RetryNonIdempotent
+	}
+}
+
+// delay returns how long to wait before retrying a request that failed with
+// response or err, and whether it should be retried at all.
+func (p *RetryPolicy) delay(method string, attempt int, response *http.Response, err error) (time.Duration, bool) {
+	if err == nil && response.StatusCode == http.StatusTooManyRequests {
+		if !p.retryableStatus(response.StatusCode) {
+			return 0, false
+		}
+
+		retryAfter := parseRetryAfter(response.Header, time.Now())
+		if retryAfter == 0 {
+			return p.backoff(attempt), true
+		}
+		if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	if !p.allows(method) || !p.shouldRetry(response, err) {
+		return 0, false
+	}
+	return p.backoff(attempt), true
+}
+
+func (p *RetryPolicy) retryableStatus(status int) bool {
+	for _, s := range p.RetryableStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) shouldRetry(response *http.Response, err error) bool {
+	if err != nil {
+		if p.RetryableError != nil {
+			return p.RetryableError(err)
+		}
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	return p.retryableStatus(response.StatusCode)
+}
+
+// backoff returns how long to wait after the given failed attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// SleepContext waits for d, or until ctx is done in which case its error is
+// returned. A d of 0 or less does not wait, but still reports a done ctx.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
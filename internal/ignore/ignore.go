@@ -0,0 +1,293 @@
+// Package ignore selects the files of a code folder to upload, following
+// ignore files written with the gitignore syntax.
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultFile is the ignore file honoured in every directory of a code
+// folder.
+const DefaultFile = ".toastignore"
+
+// GitignoreFile is honoured as well when Options.Gitignore is set.
+const GitignoreFile = ".gitignore"
+
+type Options struct {
+	// Gitignore also honours .gitignore files.
+	Gitignore bool
+
+	// Include, when not empty, only keeps the files matching one of its
+	// patterns. Exclude drops the files matching one of its patterns, on top
+	// of the ignore files. Both use the gitignore syntax, relative to the
+	// root of the folder.
+	Include []string
+	Exclude []string
+}
+
+// files returns the names of the ignore files to read in every directory.
+func (o *Options) files() []string {
+	if o.Gitignore {
+		return []string{GitignoreFile, DefaultFile}
+	}
+	return []string{DefaultFile}
+}
+
+type pattern struct {
+	// base is the directory of the ignore file, relative to the root, or ""
+	// for the root itself.
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher holds the patterns of ignore files. Later patterns take precedence
+// over earlier ones.
+type Matcher struct {
+	patterns []pattern
+}
+
+// Add parses the lines of an ignore file located in the directory base.
+func (m *Matcher) Add(base string, lines []string) error {
+	if base == "." {
+		base = ""
+	}
+
+	for _, line := range lines {
+		p, ok, err := parsePattern(base, line)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return nil
+}
+
+// match returns whether the last pattern matching name negates it, and
+// whether any pattern matched at all.
+func (m *Matcher) match(name string, isDir bool) (matched, negated bool) {
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		p := m.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		rel := name
+		if p.base != "" {
+			if !strings.HasPrefix(name, p.base+"/") {
+				continue
+			}
+			rel = name[len(p.base)+1:]
+		}
+
+		if p.re.MatchString(rel) {
+			return true, p.negate
+		}
+	}
+	return false, false
+}
+
+// Ignored returns whether name, a slash separated path relative to the root,
+// is ignored by itself. Its parent directories are not checked.
+func (m *Matcher) Ignored(name string, isDir bool) bool {
+	matched, negated := m.match(name, isDir)
+	return matched && !negated
+}
+
+// Matches returns whether name or one of its parent directories is matched,
+// the deepest match taking precedence.
+func (m *Matcher) Matches(name string) bool {
+	matches := false
+	for i := 0; i < len(name); i++ {
+		if name[i] != '/' {
+			continue
+		}
+		if matched, negated := m.match(name[:i], true); matched {
+			matches = !negated
+		}
+	}
+	if matched, negated := m.match(name, false); matched {
+		matches = !negated
+	}
+	return matches
+}
+
+func (m *Matcher) clone() *Matcher {
+	return &Matcher{patterns: m.patterns[:len(m.patterns):len(m.patterns)]}
+}
+
+// parsePattern parses a line of an ignore file. ok is false for blank lines
+// and comments.
+func parsePattern(base, line string) (p pattern, ok bool, err error) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return p, false, nil
+	}
+
+	p.base = base
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, false, nil
+	}
+
+	// Patterns with a slash other than a trailing one are relative to base,
+	// others match at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+
+	p.re, err = regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return p, false, err
+	}
+	return p, true, nil
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// Walk calls fn with the slash separated name of every file of fsys that is
+// not ignored, in lexical order. Ignored directories are not walked into, so
+// their files can not be included back by a negated pattern, as with git.
+func Walk(fsys fs.FS, opts Options, fn func(name string, d fs.DirEntry) error) error {
+	include := &Matcher{}
+	err := include.Add("", opts.Include)
+	if err != nil {
+		return err
+	}
+	exclude := &Matcher{}
+	err = exclude.Add("", opts.Exclude)
+	if err != nil {
+		return err
+	}
+
+	files := opts.files()
+	dirs := map[string]*Matcher{}
+
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		parent := dirs[path.Dir(name)]
+		if name == "." {
+			parent = &Matcher{}
+		}
+
+		if d.IsDir() {
+			if name != "." && (parent.Ignored(name, true) || exclude.Ignored(name, true)) {
+				return fs.SkipDir
+			}
+
+			m := parent.clone()
+			for _, f := range files {
+				lines, err := readLines(fsys, path.Join(name, f))
+				if err != nil {
+					return err
+				}
+				err = m.Add(name, lines)
+				if err != nil {
+					return err
+				}
+			}
+			dirs[name] = m
+			return nil
+		}
+
+		if parent.Ignored(name, false) || exclude.Ignored(name, false) {
+			return nil
+		}
+		if len(opts.Include) > 0 && !include.Matches(name) {
+			return nil
+		}
+
+		return fn(name, d)
+	})
+}
+
+// List returns the names of the files Walk would call its function with.
+func List(fsys fs.FS, opts Options) ([]string, error) {
+	var names []string
+	err := Walk(fsys, opts, func(name string, d fs.DirEntry) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func readLines(fsys fs.FS, name string) ([]string, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	return lines, s.Err()
+}
@@ -0,0 +1,235 @@
+package ignore
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatcherIgnored(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{"blank and comments", "", []string{"", "# main.go", "   "}, "main.go", false, false},
+		{"name at any depth", "", []string{"*.log"}, "a/b/debug.log", false, true},
+		{"name does not match suffix", "", []string{"*.log"}, "debug.log.txt", false, false},
+		{"star does not cross slashes", "", []string{"a/*.go"}, "a/b/c.go", false, false},
+		{"anchored with leading slash", "", []string{"/build"}, "build", true, true},
+		{"anchored not deeper", "", []string{"/build"}, "src/build", true, false},
+		{"inner slash anchors", "", []string{"docs/*.md"}, "x/docs/a.md", false, false},
+		{"inner slash matches at root", "", []string{"docs/*.md"}, "docs/a.md", false, true},
+		{"leading double star", "", []string{"**/cache"}, "a/b/cache", true, true},
+		{"leading double star at root", "", []string{"**/cache"}, "cache", true, true},
+		{"inner double star", "", []string{"a/**/z"}, "a/b/c/z", false, true},
+		{"inner double star no dir", "", []string{"a/**/z"}, "a/z", false, true},
+		{"trailing double star", "", []string{"vendor/**"}, "vendor/x/y.go", false, true},
+		{"question mark", "", []string{"?.txt"}, "a.txt", false, true},
+		{"question mark not slash", "", []string{"a?b"}, "a/b", false, false},
+		{"character class", "", []string{"[abc].go"}, "b.go", false, true},
+		{"negated character class", "", []string{"[!abc].go"}, "b.go", false, false},
+		{"unclosed class is literal", "", []string{"[ab"}, "[ab", false, true},
+		{"escaped star", "", []string{`\*.go`}, "*.go", false, true},
+		{"escaped star is literal", "", []string{`\*.go`}, "main.go", false, false},
+		{"escaped hash", "", []string{`\#notes`}, "#notes", false, true},
+		{"escaped trailing space", "", []string{`a\ `}, "a ", false, true},
+		{"trailing spaces trimmed", "", []string{"a.go   "}, "a.go", false, true},
+		{"dir only matches dirs", "", []string{"tmp/"}, "tmp", true, true},
+		{"dir only skips files", "", []string{"tmp/"}, "tmp", false, false},
+		{"negation", "", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"last pattern wins", "", []string{"!keep.log", "*.log"}, "keep.log", false, true},
+		{"regexp metacharacters", "", []string{"a+b.(c)"}, "a+b.(c)", false, true},
+		{"base scopes patterns", "sub", []string{"*.tmp"}, "sub/x/a.tmp", false, true},
+		{"base does not match outside", "sub", []string{"*.tmp"}, "other/a.tmp", false, false},
+		{"base anchors relative to it", "sub", []string{"/out"}, "sub/out", true, true},
+		{"base anchored not root", "sub", []string{"/out"}, "out", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Matcher{}
+			if err := m.Add(tt.base, tt.patterns); err != nil {
+				t.Fatal(err)
+			}
+
+			got := m.Ignored(tt.path, tt.isDir)
+			if got != tt.want {
+				t.Errorf("%q in %q: Ignored(%q, %v) = %v, want %v", tt.patterns, tt.base, tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{"file", []string{"main.go"}, "main.go", true},
+		{"parent directory", []string{"src"}, "src/a/b.go", true},
+		{"dir only parent", []string{"src/"}, "src/b.go", true},
+		{"dir only not a file", []string{"src/"}, "src", false},
+		{"deepest match wins", []string{"src", "!src/gen"}, "src/gen/a.go", false},
+		{"file overrides parent", []string{"src", "!*.md"}, "src/README.md", false},
+		{"no match", []string{"src"}, "lib/a.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Matcher{}
+			if err := m.Add("", tt.patterns); err != nil {
+				t.Fatal(err)
+			}
+
+			got := m.Matches(tt.path)
+			if got != tt.want {
+				t.Errorf("%q: Matches(%q) = %v, want %v", tt.patterns, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	fsys := fstest.MapFS{
+		".toastignore":          {Data: []byte("# build output\n/build/\n*.log\n!keep.log\n")},
+		".gitignore":            {Data: []byte("secret.env\n")},
+		"main.go":               {},
+		"secret.env":            {},
+		"debug.log":             {},
+		"keep.log":              {},
+		"build/app":             {},
+		"src/build/gen.go":      {},
+		"src/.toastignore":      {Data: []byte("*.tmp\n!/important.log\n")},
+		"src/a.go":              {},
+		"src/a.tmp":             {},
+		"src/important.log":     {},
+		"src/deep/b.tmp":        {},
+		"src/deep/c.go":         {},
+		"docs/guide.md":         {},
+		"docs/img/logo.png":     {},
+		"node_modules/x/index":  {},
+		"node_modules/x/.keep":  {},
+		"other/.toastignore":    {Data: []byte("*\n")},
+		"other/ignored_by_self": {},
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "toastignore files",
+			opts: Options{},
+			want: []string{
+				".gitignore",
+				".toastignore",
+				"docs/guide.md",
+				"docs/img/logo.png",
+				"keep.log",
+				"main.go",
+				"node_modules/x/.keep",
+				"node_modules/x/index",
+				"secret.env",
+				"src/.toastignore",
+				"src/a.go",
+				"src/build/gen.go",
+				"src/deep/c.go",
+				"src/important.log",
+			},
+		},
+		{
+			name: "gitignore files",
+			opts: Options{Gitignore: true},
+			want: []string{
+				".gitignore",
+				".toastignore",
+				"docs/guide.md",
+				"docs/img/logo.png",
+				"keep.log",
+				"main.go",
+				"node_modules/x/.keep",
+				"node_modules/x/index",
+				"src/.toastignore",
+				"src/a.go",
+				"src/build/gen.go",
+				"src/deep/c.go",
+				"src/important.log",
+			},
+		},
+		{
+			name: "exclude",
+			opts: Options{Exclude: []string{"node_modules/", ".*", "*.md"}},
+			want: []string{
+				"docs/img/logo.png",
+				"keep.log",
+				"main.go",
+				"secret.env",
+				"src/a.go",
+				"src/build/gen.go",
+				"src/deep/c.go",
+				"src/important.log",
+			},
+		},
+		{
+			name: "include",
+			opts: Options{Include: []string{"*.go", "docs"}},
+			want: []string{
+				"docs/guide.md",
+				"docs/img/logo.png",
+				"main.go",
+				"src/a.go",
+				"src/build/gen.go",
+				"src/deep/c.go",
+			},
+		},
+		{
+			name: "include and exclude",
+			opts: Options{Include: []string{"src"}, Exclude: []string{"deep/"}},
+			want: []string{
+				"src/.toastignore",
+				"src/a.go",
+				"src/build/gen.go",
+				"src/important.log",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := List(fsys, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListIgnoredDirNotReincluded(t *testing.T) {
+	// As with git, a file can not be included back when its directory is
+	// ignored.
+	fsys := fstest.MapFS{
+		".toastignore":  {Data: []byte("logs/\n!logs/keep.txt\n")},
+		"logs/keep.txt": {},
+		"logs/other":    {},
+		"main.go":       {},
+	}
+
+	got, err := List(fsys, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".toastignore", "main.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,218 @@
+package toastcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/internal/apiclient"
+)
+
+const (
+	// DefaultBuildMinDelay and DefaultBuildMaxDelay bound the delay between
+	// two checks of WaitForBuild, which doubles after every check.
+	DefaultBuildMinDelay = time.Second
+	DefaultBuildMaxDelay = 15 * time.Second
+)
+
+type GetBuildInput struct {
+	ID      string `json:"id,omitempty"`
+	BuildID string `json:"build_id,omitempty"`
+}
+
+type GetBuildOutput struct {
+	Build *models.Build `json:"build,omitempty"`
+	// BuildLogs are only sent once the build is done.
+	BuildLogs []byte `json:"build_logs,omitempty"`
+}
+
+type getBuildResponse struct {
+	Success   bool          `json:"success"`
+	Build     *models.Build `json:"build,omitempty"`
+	BuildLogs []byte        `json:"build_logs,omitempty"`
+}
+
+func (sess *Session) GetBuild(input *GetBuildInput) (*GetBuildOutput, error) {
+	return sess.GetBuildWithContext(context.Background(), input)
+}
+
+// GetBuildWithContext returns the status and progress of a build.
+func (sess *Session) GetBuildWithContext(ctx context.Context, input *GetBuildInput) (*GetBuildOutput, error) {
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+	if err := BuildID(input.BuildID).Validate(); err != nil {
+		return nil, err
+	}
+
+	resp := &getBuildResponse{}
+
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/build/"+input.ID+"/"+input.BuildID, resp)
+	if err != nil {
+		return nil, err
+	}
+	if apierr != nil {
+		return nil, newAPIError(apierr)
+	}
+
+	if !resp.Success {
+		return nil, ErrUnexpectedFailure
+	}
+
+	if resp.Build == nil {
+		return nil, ErrEmptyResponse
+	}
+
+	return &GetBuildOutput{
+		Build:     resp.Build,
+		BuildLogs: resp.BuildLogs,
+	}, nil
+}
+
+type WaitForBuildInput struct {
+	ID      string `json:"id,omitempty"`
+	BuildID string `json:"build_id,omitempty"`
+
+	// MinDelay and MaxDelay default to DefaultBuildMinDelay and
+	// DefaultBuildMaxDelay.
+	MinDelay time.Duration `json:"-"`
+	MaxDelay time.Duration `json:"-"`
+
+	// Progress, when set, is called with the build after every check.
+	Progress func(build *models.Build) `json:"-"`
+}
+
+type WaitForBuildOutput struct {
+	Build     *models.Build `json:"build,omitempty"`
+	BuildLogs []byte        `json:"build_logs,omitempty"`
+}
+
+func (sess *Session) WaitForBuild(input *WaitForBuildInput) (*WaitForBuildOutput, error) {
+	return sess.WaitForBuildWithContext(context.Background(), input)
+}
+
+// WaitForBuildWithContext polls a build until it is done, or ctx is done.
+// When the build failed, the output is returned along with an error wrapping
+// ErrBuildFailed, so that its logs can be inspected. A status other than
+// queued, running, succeeded or failed is returned as an error too.
+func (sess *Session) WaitForBuildWithContext(ctx context.Context, input *WaitForBuildInput) (*WaitForBuildOutput, error) {
+	delay := input.MinDelay
+	if delay <= 0 {
+		delay = DefaultBuildMinDelay
+	}
+	maxDelay := input.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultBuildMaxDelay
+	}
+
+	for {
+		out, err := sess.GetBuildWithContext(ctx, &GetBuildInput{ID: input.ID, BuildID: input.BuildID})
+		if err != nil {
+			return nil, err
+		}
+		if input.Progress != nil {
+			input.Progress(out.Build)
+		}
+
+		switch out.Build.Status {
+		case models.BuildStatusSucceeded:
+			return &WaitForBuildOutput{Build: out.Build, BuildLogs: out.BuildLogs}, nil
+		case models.BuildStatusFailed:
+			return &WaitForBuildOutput{Build: out.Build, BuildLogs: out.BuildLogs}, fmt.Errorf("%w: %s", ErrBuildFailed, out.Build.Error)
+		case models.BuildStatusQueued, models.BuildStatusRunning:
+			// Not done yet.
+		default:
+			// Waiting for a status that may never change would never end.
+			return &WaitForBuildOutput{Build: out.Build, BuildLogs: out.BuildLogs}, fmt.Errorf("unknown status %q of build %s", out.Build.Status, out.Build.ID)
+		}
+
+		err = apiclient.SleepContext(ctx, delay)
+		if err != nil {
+			return nil, err
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+type StreamBuildLogsInput struct {
+	ID      string `json:"id,omitempty"`
+	BuildID string `json:"build_id,omitempty"`
+
+	// Offset is the number of bytes of logs to skip, e.g. LogStream.Offset
+	// of a previous stream to resume it.
+	Offset int64 `json:"offset,omitempty"`
+
+	// Follow keeps the stream open until the build is done.
+	Follow bool `json:"follow,omitempty"`
+
+	// MaxReconnects works as in StreamToasterLogsInput.
+	MaxReconnects int `json:"max_reconnects,omitempty"`
+}
+
+type StreamBuildLogsOutput struct {
+	Stream *LogStream
+}
+
+func (sess *Session) StreamBuildLogs(input *StreamBuildLogsInput) (*StreamBuildLogsOutput, error) {
+	return sess.StreamBuildLogsWithContext(context.Background(), input)
+}
+
+// StreamBuildLogsWithContext opens a stream of the output of a build. ctx
+// bounds the whole life of the stream, not only this call.
+func (sess *Session) StreamBuildLogsWithContext(ctx context.Context, input *StreamBuildLogsInput) (*StreamBuildLogsOutput, error) {
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+	if err := BuildID(input.BuildID).Validate(); err != nil {
+		return nil, err
+	}
+
+	s, err := sess.openLogStream(ctx, "/toaster/build/logs/stream/"+input.ID+"/"+input.BuildID, input.Offset, input.Follow, input.MaxReconnects)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamBuildLogsOutput{
+		Stream: s,
+	}, nil
+}
+
+// followBuild copies the output of a build of the toaster id to w as it is
+// produced, then returns the build once done. A failed build is not an
+// error: its status tells it, as for builds that are not followed.
+func (sess *Session) followBuild(ctx context.Context, id string, build *models.Build, w io.Writer) (*WaitForBuildOutput, error) {
+	out, err := sess.StreamBuildLogsWithContext(ctx, &StreamBuildLogsInput{
+		ID:      id,
+		BuildID: build.ID,
+		Follow:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(w, out.Stream)
+	out.Stream.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream ends with the build, which is then done unless the API is
+	// lagging behind.
+	done, err := sess.WaitForBuildWithContext(ctx, &WaitForBuildInput{
+		ID:       id,
+		BuildID:  build.ID,
+		MinDelay: 100 * time.Millisecond,
+	})
+	if err != nil && !errors.Is(err, ErrBuildFailed) {
+		return nil, err
+	}
+
+	return done, nil
+}
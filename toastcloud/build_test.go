@@ -0,0 +1,287 @@
+package toastcloud_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+// rewriteResponses returns a middleware editing the JSON responses to the
+// requests whose path starts with prefix.
+func rewriteResponses(prefix string, rewrite func(body map[string]interface{})) toastcloud.Option {
+	return toastcloud.WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil || !strings.HasPrefix(req.URL.Path, prefix) {
+				return resp, err
+			}
+
+			body := map[string]interface{}{}
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			rewrite(body)
+
+			b, err := json.Marshal(body)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(b))
+			resp.ContentLength = int64(len(b))
+			resp.Header.Del("Content-Length")
+			return resp, nil
+		})
+	})
+}
+
+func TestGetBuild(t *testing.T) {
+	_, sess := newTestServer(t)
+
+	created, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+		Codes:     [][]byte{[]byte("package main")},
+		CodePaths: []string{"main.go"},
+		ExeCmd:    []string{"./app"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := sess.GetBuild(&toastcloud.GetBuildInput{ID: created.Toaster.ID, BuildID: created.Build.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Build.ID != created.Build.ID || out.Build.ToasterID != created.Toaster.ID {
+		t.Errorf("build = %+v, want %s of %s", out.Build, created.Build.ID, created.Toaster.ID)
+	}
+	if out.Build.Status != models.BuildStatusSucceeded || len(out.BuildLogs) == 0 {
+		t.Errorf("build = %+v with logs %q, want a succeeded build with logs", out.Build, out.BuildLogs)
+	}
+
+	_, err = sess.GetBuild(&toastcloud.GetBuildInput{ID: created.Toaster.ID, BuildID: "b_unknown"})
+	if !errors.Is(err, &toastcloud.APIError{Code: "build_not_found"}) {
+		t.Errorf("GetBuild of an unknown build = %v, want build_not_found", err)
+	}
+}
+
+func TestWaitForBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure string
+		status  string
+	}{
+		{"succeeded", "", models.BuildStatusSucceeded},
+		{"failed", "compilation error", models.BuildStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			srv.SetBuildDuration(50 * time.Millisecond)
+
+			created, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+				Codes:     [][]byte{[]byte("package main")},
+				CodePaths: []string{"main.go"},
+				ExeCmd:    []string{"./app"},
+				Async:     true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.SetBuildFailure(created.Toaster.ID, tt.failure)
+			if tt.failure != "" {
+				// The failure only applies to the next builds.
+				updated, err := sess.UpdateToaster(&toastcloud.UpdateToasterInput{ID: created.Toaster.ID, Async: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+				created.Build = updated.Build
+			}
+
+			checks := 0
+			out, err := sess.WaitForBuild(&toastcloud.WaitForBuildInput{
+				ID:       created.Toaster.ID,
+				BuildID:  created.Build.ID,
+				MinDelay: 5 * time.Millisecond,
+				MaxDelay: 10 * time.Millisecond,
+				Progress: func(*models.Build) { checks++ },
+			})
+			if tt.failure == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.failure != "" && !errors.Is(err, toastcloud.ErrBuildFailed) {
+				t.Fatalf("WaitForBuild = %v, want ErrBuildFailed", err)
+			}
+
+			if out == nil || out.Build.Status != tt.status {
+				t.Fatalf("WaitForBuild = %+v, want status %s", out, tt.status)
+			}
+			if checks < 2 {
+				t.Errorf("Progress called %d times, want the running build too", checks)
+			}
+			if tt.failure != "" && !bytes.Contains(out.BuildLogs, []byte(tt.failure)) {
+				t.Errorf("logs = %q, want the failure", out.BuildLogs)
+			}
+		})
+	}
+}
+
+func TestWaitForBuildUnknownStatus(t *testing.T) {
+	srv, sess := newTestServer(t)
+
+	created, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+		Codes:     [][]byte{[]byte("package main")},
+		CodePaths: []string{"main.go"},
+		ExeCmd:    []string{"./app"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess = srv.Session(rewriteResponses("/toaster/build/", func(body map[string]interface{}) {
+		body["build"].(map[string]interface{})["status"] = "cancelled"
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := sess.WaitForBuildWithContext(ctx, &toastcloud.WaitForBuildInput{
+		ID:       created.Toaster.ID,
+		BuildID:  created.Build.ID,
+		MinDelay: time.Millisecond,
+	})
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForBuild = %v, want an error for the unknown status", err)
+	}
+	if out == nil || out.Build.Status != "cancelled" {
+		t.Errorf("WaitForBuild = %+v, want the build", out)
+	}
+}
+
+func TestCreateToasterMissingBuild(t *testing.T) {
+	srv, _ := newTestServer(t)
+	sess := srv.Session(rewriteResponses("/toaster", func(body map[string]interface{}) {
+		delete(body, "build")
+	}))
+
+	out, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+		Codes:     [][]byte{[]byte("package main")},
+		CodePaths: []string{"main.go"},
+		ExeCmd:    []string{"./app"},
+		Async:     true,
+	})
+	if !errors.Is(err, toastcloud.ErrEmptyResponse) {
+		t.Fatalf("CreateToaster = %v, want ErrEmptyResponse", err)
+	}
+	if out == nil || out.Toaster == nil || out.Toaster.ID == "" {
+		t.Fatalf("CreateToaster = %+v, want the created toaster", out)
+	}
+
+	_, err = sess.GetToaster(&toastcloud.GetToasterInput{ID: out.Toaster.ID})
+	if err != nil {
+		t.Errorf("GetToaster of the returned toaster = %v", err)
+	}
+}
+
+func TestStreamBuildLogs(t *testing.T) {
+	srv, sess := newTestServer(t)
+	srv.SetBuildDuration(50 * time.Millisecond)
+
+	created, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+		Codes:     [][]byte{[]byte("package main")},
+		CodePaths: []string{"main.go"},
+		ExeCmd:    []string{"./app"},
+		Async:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := sess.StreamBuildLogs(&toastcloud.StreamBuildLogsInput{ID: created.Toaster.ID, BuildID: created.Build.ID, Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := io.ReadAll(out.Stream)
+	out.Stream.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stream only ends with the build, whose logs are then complete.
+	build, err := sess.GetBuild(&toastcloud.GetBuildInput{ID: created.Toaster.ID, BuildID: created.Build.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if build.Build.Status != models.BuildStatusSucceeded {
+		t.Errorf("status = %s once the stream ended, want %s", build.Build.Status, models.BuildStatusSucceeded)
+	}
+	if len(logs) == 0 || !bytes.Equal(logs, build.BuildLogs) {
+		t.Errorf("streamed logs = %q, want %q", logs, build.BuildLogs)
+	}
+}
+
+func TestCreateToasterBuildLogWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault *toastcloudtest.Fault
+	}{
+		{name: "followed"},
+		{
+			name:  "follow failure",
+			fault: &toastcloudtest.Fault{Path: "/toaster/build/logs/stream/", Status: http.StatusNotFound, Code: "build_not_found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			srv.SetBuildDuration(50 * time.Millisecond)
+			if tt.fault != nil {
+				srv.InjectFault(*tt.fault)
+			}
+
+			var logs bytes.Buffer
+			out, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+				Codes:          [][]byte{[]byte("package main")},
+				CodePaths:      []string{"main.go"},
+				ExeCmd:         []string{"./app"},
+				BuildLogWriter: &logs,
+			})
+
+			if tt.fault != nil {
+				if !errors.Is(err, &toastcloud.APIError{Code: tt.fault.Code}) {
+					t.Fatalf("CreateToaster = %v, want %s", err, tt.fault.Code)
+				}
+				// The toaster exists, the output is kept.
+				if out == nil || out.Toaster == nil || out.Build == nil {
+					t.Fatalf("CreateToaster = %+v, want the toaster and its started build", out)
+				}
+				if _, ok := srv.Toaster(out.Toaster.ID); !ok {
+					t.Errorf("toaster %s is not on the server", out.Toaster.ID)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Build.Status != models.BuildStatusSucceeded {
+				t.Errorf("status = %s, want the followed build done", out.Build.Status)
+			}
+			if logs.Len() == 0 || !bytes.Equal(logs.Bytes(), out.BuildLogs) {
+				t.Errorf("written logs = %q, want %q", logs.Bytes(), out.BuildLogs)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package toastcloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/internal/apiclient"
+	"github.com/toastate/toastate-sdk-go/internal/ignore"
+)
+
+func (input *CreateToasterInput) codeFolderOptions() ignore.Options {
+	return ignore.Options{
+		Gitignore: input.CodeFolderGitignore,
+		Include:   input.CodeFolderInclude,
+		Exclude:   input.CodeFolderExclude,
+	}
+}
+
+// CodeFolderFiles lists the files of CodeFolder or CodeFS that CreateToaster
+// uploads, relative to their root and slash separated. No request is sent.
+func (input *CreateToasterInput) CodeFolderFiles() ([]string, error) {
+	fsys, err := codeFS(input.CodeFolder, input.CodeFS, input.CodeFSRoot)
+	if err != nil {
+		return nil, err
+	}
+	return ignore.List(fsys, input.codeFolderOptions())
+}
+
+func (input *UpdateToasterInput) codeFolderOptions() ignore.Options {
+	return ignore.Options{
+		Gitignore: input.CodeFolderGitignore,
+		Include:   input.CodeFolderInclude,
+		Exclude:   input.CodeFolderExclude,
+	}
+}
+
+// CodeFolderFiles lists the files of CodeFolder or CodeFS that UpdateToaster
+// uploads, relative to their root and slash separated. No request is sent.
+func (input *UpdateToasterInput) CodeFolderFiles() ([]string, error) {
+	fsys, err := codeFS(input.CodeFolder, input.CodeFS, input.CodeFSRoot)
+	if err != nil {
+		return nil, err
+	}
+	return ignore.List(fsys, input.codeFolderOptions())
+}
+
+// codeFS returns the file system of the code of a toaster, CodeFS under
+// root when set, or else the CodeFolder directory.
+func codeFS(folder string, fsys fs.FS, root string) (fs.FS, error) {
+	if fsys != nil {
+		if root == "" || root == "." {
+			return fsys, nil
+		}
+
+		sub, err := fs.Sub(fsys, root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CodeFSRoot: %w", err)
+		}
+		return sub, nil
+	}
+
+	if folder == "" {
+		return nil, fmt.Errorf("you did not provide a CodeFolder nor a CodeFS")
+	}
+
+	// os.DirFS only reports a missing folder relative to itself.
+	info, err := os.Stat(folder)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CodeFolder: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid CodeFolder: %s is not a directory", folder)
+	}
+	return os.DirFS(folder), nil
+}
+
+// newCodeArchive returns the prebuilt archive of the code of a toaster,
+// read from r or else from file.
+func newCodeArchive(r io.Reader, file string, format ArchiveFormat) (*apiclient.Archive, error) {
+	if r != nil {
+		if format == "" {
+			return nil, fmt.Errorf("you did not provide the CodeArchiveFormat of the CodeArchive")
+		}
+
+		return &apiclient.Archive{
+			Open:   func() (io.ReadCloser, error) { return io.NopCloser(r), nil },
+			Format: format,
+		}, nil
+	}
+
+	if format == "" {
+		switch {
+		case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+			format = ArchiveTarGz
+		case strings.HasSuffix(file, ".zip"):
+			format = ArchiveZip
+		default:
+			return nil, fmt.Errorf("can not tell the format of %q from its extension, set CodeArchiveFormat", file)
+		}
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiclient.Archive{
+		Open:       func() (io.ReadCloser, error) { return os.Open(file) },
+		Replayable: true,
+		Format:     format,
+		Size:       info.Size(),
+	}, nil
+}
+
+// DeltaUpload reports what a delta update of a toaster uploaded.
+type DeltaUpload struct {
+	// Uploaded are the new or changed files, Deleted the deployed files
+	// that are gone, and Unchanged the number of files that were not
+	// uploaded since they are deployed with the same content.
+	Uploaded  []string `json:"uploaded,omitempty"`
+	Deleted   []string `json:"deleted,omitempty"`
+	Unchanged int      `json:"unchanged,omitempty"`
+
+	BytesUploaded int64 `json:"bytes_uploaded,omitempty"`
+	// BytesSaved is the size of the unchanged files.
+	BytesSaved int64 `json:"bytes_saved,omitempty"`
+}
+
+// codeDelta compares the files of folder to the deployed files of a toaster
+// and returns the names of the files to upload. It returns a nil delta when
+// the API does not report the hashes of the deployed files, in which case
+// the whole folder must be uploaded.
+func (sess *Session) codeDelta(ctx context.Context, id string, folder *apiclient.Folder) (*DeltaUpload, map[string]bool, error) {
+	deployed, err := sess.ListToasterFilesWithContext(ctx, &ListToasterFilesInput{ID: id, WithHashes: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	// Without hashes, every file would look changed and none deleted: the
+	// files removed from folder would stay deployed.
+	if len(deployed.Files) > 0 && len(deployed.Hashes) == 0 {
+		return nil, nil, nil
+	}
+	remote := make(map[string]models.FileHash, len(deployed.Hashes))
+	for _, h := range deployed.Hashes {
+		remote[h.Path] = h
+	}
+
+	delta := &DeltaUpload{}
+	upload := map[string]bool{}
+	err = ignore.Walk(folder.FS, folder.Ignore, func(name string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		local, err := hashFile(folder.FS, name)
+		if err != nil {
+			return err
+		}
+
+		if h, ok := remote[name]; ok && h.SHA256 == local.SHA256 {
+			delta.Unchanged++
+			delta.BytesSaved += local.Size
+		} else {
+			upload[name] = true
+			delta.Uploaded = append(delta.Uploaded, name)
+			delta.BytesUploaded += local.Size
+		}
+		delete(remote, name)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keep the order of the API.
+	for _, h := range deployed.Hashes {
+		if _, ok := remote[h.Path]; ok {
+			delta.Deleted = append(delta.Deleted, h.Path)
+		}
+	}
+
+	return delta, upload, nil
+}
+
+func hashFile(fsys fs.FS, name string) (models.FileHash, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return models.FileHash{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return models.FileHash{}, err
+	}
+
+	return models.FileHash{
+		Path:   name,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+		Size:   n,
+	}, nil
+}
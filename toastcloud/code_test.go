@@ -0,0 +1,334 @@
+package toastcloud_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+// codeFiles are the files of the code uploaded by the tests, .toastignore
+// included.
+var codeFiles = map[string]string{
+	".toastignore": "*.log\n",
+	"main.go":      "package main",
+	"lib/util.go":  "package lib",
+	"debug.log":    "not uploaded",
+}
+
+// uploadedFiles are the files of codeFiles that are not ignored.
+var uploadedFiles = map[string]string{
+	".toastignore": "*.log\n",
+	"main.go":      "package main",
+	"lib/util.go":  "package lib",
+}
+
+func codeStream(files map[string]string) chan *models.MultipartItem {
+	ch := make(chan *models.MultipartItem, len(files)+1)
+	for name, content := range files {
+		ch <- &models.MultipartItem{Filename: name, R: io.NopCloser(strings.NewReader(content))}
+	}
+	ch <- nil
+	return ch
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCreateToasterCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input func(t *testing.T) *toastcloud.CreateToasterInput
+		want  map[string]string
+	}{
+		{
+			name: "folder",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{CodeFolder: writeCodeFolder(t, codeFiles)}
+			},
+			want: uploadedFiles,
+		},
+		{
+			name: "folder excluding files",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{
+					CodeFolder:        writeCodeFolder(t, codeFiles),
+					CodeFolderExclude: []string{"lib/", ".*"},
+				}
+			},
+			want: map[string]string{"main.go": "package main"},
+		},
+		{
+			name: "folder with gitignore",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				files := map[string]string{".gitignore": "*.env\n!public.env\n", "secret.env": "TOKEN=1", "public.env": "PORT=80"}
+				for name, content := range codeFiles {
+					files[name] = content
+				}
+				return &toastcloud.CreateToasterInput{
+					CodeFolder:          writeCodeFolder(t, files),
+					CodeFolderGitignore: true,
+				}
+			},
+			want: map[string]string{
+				".gitignore":   "*.env\n!public.env\n",
+				".toastignore": "*.log\n",
+				"main.go":      "package main",
+				"lib/util.go":  "package lib",
+				"public.env":   "PORT=80",
+			},
+		},
+		{
+			name: "folder as tar.gz",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{
+					CodeFolder:        writeCodeFolder(t, codeFiles),
+					CodeArchiveFormat: toastcloud.ArchiveTarGz,
+				}
+			},
+			want: uploadedFiles,
+		},
+		{
+			name: "fs with root",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				fsys := fstest.MapFS{}
+				for name, content := range codeFiles {
+					fsys["app/"+name] = &fstest.MapFile{Data: []byte(content)}
+				}
+				fsys["other/file"] = &fstest.MapFile{Data: []byte("outside of the root")}
+				return &toastcloud.CreateToasterInput{CodeFS: fsys, CodeFSRoot: "app"}
+			},
+			want: uploadedFiles,
+		},
+		{
+			name: "stream",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{CodeStream: codeStream(uploadedFiles)}
+			},
+			want: uploadedFiles,
+		},
+		{
+			name: "stream as zip",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{
+					CodeStream:        codeStream(uploadedFiles),
+					CodeArchiveFormat: toastcloud.ArchiveZip,
+				}
+			},
+			want: uploadedFiles,
+		},
+		{
+			name: "archive reader",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{
+					CodeArchive:       bytes.NewReader(zipArchive(t, uploadedFiles)),
+					CodeArchiveFormat: toastcloud.ArchiveZip,
+				}
+			},
+			want: uploadedFiles,
+		},
+		{
+			name: "archive file",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				path := filepath.Join(t.TempDir(), "code.zip")
+				if err := os.WriteFile(path, zipArchive(t, uploadedFiles), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return &toastcloud.CreateToasterInput{CodeArchiveFile: path}
+			},
+			want: uploadedFiles,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+
+			input := tt.input(t)
+			input.ExeCmd = []string{"./app"}
+			out, err := sess.CreateToaster(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			for name, content := range srv.Files(out.Toaster.ID) {
+				got[name] = string(content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uploaded files = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeFolderFiles(t *testing.T) {
+	input := &toastcloud.CreateToasterInput{CodeFolder: writeCodeFolder(t, codeFiles)}
+	got, err := input.CodeFolderFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for name := range uploadedFiles {
+		want = append(want, name)
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeFolderFiles = %q, want %q", got, want)
+	}
+}
+
+func TestCreateToasterMissingFolder(t *testing.T) {
+	srv, sess := newTestServer(t)
+
+	folder := filepath.Join(t.TempDir(), "missing")
+	_, err := sess.CreateToaster(&toastcloud.CreateToasterInput{CodeFolder: folder})
+	if err == nil || !strings.Contains(err.Error(), folder) {
+		t.Errorf("CreateToaster = %v, want an error naming %s", err, folder)
+	}
+	srv.AssertNotRequested(t, "POST", "/toaster")
+}
+
+func TestInvalidArchiveFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input func(t *testing.T) *toastcloud.CreateToasterInput
+	}{
+		{
+			name: "folder",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{CodeFolder: writeCodeFolder(t, codeFiles)}
+			},
+		},
+		{
+			name: "fs",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{CodeFS: fstest.MapFS{"main.go": {Data: []byte("package main")}}}
+			},
+		},
+		{
+			name: "stream",
+			input: func(t *testing.T) *toastcloud.CreateToasterInput {
+				return &toastcloud.CreateToasterInput{CodeStream: codeStream(uploadedFiles)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t)
+			rec := &requestRecorder{}
+			sess := srv.Session(toastcloud.WithMiddleware(rec.wrap))
+
+			input := tt.input(t)
+			input.ExeCmd = []string{"./app"}
+			input.CodeArchiveFormat = "rar"
+			_, err := sess.CreateToaster(input)
+			if err == nil || !strings.Contains(err.Error(), `"rar"`) {
+				t.Errorf("CreateToaster = %v, want an unsupported format error", err)
+			}
+			// The format is checked before the upload starts.
+			if n := rec.sent("POST /toaster"); n != 0 {
+				t.Errorf("%d requests sent, want none", n)
+			}
+		})
+	}
+}
+
+func TestUpdateToasterDelta(t *testing.T) {
+	srv, sess := newTestServer(t)
+
+	toaster := srv.AddToaster(toasterModel("delta"), map[string][]byte{
+		".toastignore": []byte("*.log\n"),
+		"main.go":      []byte("package main"),
+		"lib/util.go":  []byte("package old"),
+		"removed.go":   []byte("package removed"),
+	})
+
+	out, err := sess.UpdateToaster(&toastcloud.UpdateToasterInput{
+		ID:              toaster.ID,
+		CodeFolder:      writeCodeFolder(t, codeFiles),
+		CodeFolderDelta: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &toastcloud.DeltaUpload{
+		Uploaded:      []string{"lib/util.go"},
+		Deleted:       []string{"removed.go"},
+		Unchanged:     2,
+		BytesUploaded: int64(len("package lib")),
+		BytesSaved:    int64(len("*.log\n") + len("package main")),
+	}
+	if !reflect.DeepEqual(out.Delta, want) {
+		t.Errorf("Delta = %+v, want %+v", out.Delta, want)
+	}
+
+	req := srv.AssertRequested(t, "PUT", "/toaster/"+toaster.ID)
+	if len(req.Files) != 1 || string(req.Files["lib/util.go"]) != "package lib" {
+		t.Errorf("uploaded files = %q, want only lib/util.go", req.Files)
+	}
+
+	got := map[string]string{}
+	for name, content := range srv.Files(toaster.ID) {
+		got[name] = string(content)
+	}
+	if !reflect.DeepEqual(got, uploadedFiles) {
+		t.Errorf("deployed files = %q, want %q", got, uploadedFiles)
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	_, sess := newTestServer(t)
+
+	var last toastcloud.UploadProgress
+	var reports int
+	_, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+		CodeFolder: writeCodeFolder(t, codeFiles),
+		ExeCmd:     []string{"./app"},
+		UploadProgress: func(p toastcloud.UploadProgress) {
+			reports++
+			last = p
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	for _, content := range uploadedFiles {
+		total += int64(len(content))
+	}
+	if reports < len(uploadedFiles) {
+		t.Errorf("%d progress reports, want at least one per file", reports)
+	}
+	if !last.Done || last.Files != len(uploadedFiles) || last.BytesSent != total || last.TotalBytes != total {
+		t.Errorf("last progress = %+v, want done with %d files and %d bytes", last, len(uploadedFiles), total)
+	}
+}
@@ -5,4 +5,18 @@ const (
 	ExecutionIDPrefix          = "ex_"
 	ExecutionForcedExeIDPrefix = "fex_"
 	SessionPrefix              = "sess_"
+	APIKeyPrefix               = "key_"
+	BuildIDPrefix              = "b_"
+
+	// ExecutionIDHeader carries the ID of the execution that served a
+	// request sent to a toaster.
+	ExecutionIDHeader = "X-TOASTATE-EXEID"
+
+	// ForceNewExecutionHeader asks for the request to be served by a new
+	// execution instead of joining a running one.
+	ForceNewExecutionHeader = "X-TOASTATE-FORCE-NEW"
+
+	// JoinStatusHeader reports the outcome of a request that asked to join
+	// an execution by sending its ID in ExecutionIDHeader.
+	JoinStatusHeader = "X-TOASTATE-JOIN-STATUS"
 )
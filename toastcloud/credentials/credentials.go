@@ -0,0 +1,125 @@
+// Package credentials resolves the credentials used to authenticate a
+// toastcloud.Session, from explicit values, environment variables or a
+// shared credentials file holding named profiles.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	EnvToken           = "TOASTATE_TOKEN"
+	EnvAPIKey          = "TOASTATE_API_KEY"
+	EnvAPISecret       = "TOASTATE_API_SECRET"
+	EnvProfile         = "TOASTATE_PROFILE"
+	EnvCredentialsFile = "TOASTATE_CREDENTIALS_FILE"
+
+	DefaultProfile = "default"
+)
+
+// ErrNoCredentials is returned by a Provider that did not find any
+// credentials. A ChainProvider then moves on to its next provider.
+var ErrNoCredentials = errors.New("no credentials found")
+
+// Value holds resolved credentials. Either Token or APIKey is set.
+type Value struct {
+	Token     string
+	APIKey    string
+	APISecret string
+
+	// APIURL and APIVersion, when set, override the API endpoint used by the
+	// session.
+	APIURL     string
+	APIVersion string
+
+	// ProviderName is the name of the provider the value comes from.
+	ProviderName string
+}
+
+func (v Value) IsEmpty() bool {
+	return v.Token == "" && v.APIKey == ""
+}
+
+type Provider interface {
+	Retrieve() (Value, error)
+}
+
+type StaticProvider struct {
+	Value Value
+}
+
+func NewStaticProvider(token, apiKey, apiSecret string) *StaticProvider {
+	return &StaticProvider{
+		Value: Value{
+			Token:     token,
+			APIKey:    apiKey,
+			APISecret: apiSecret,
+		},
+	}
+}
+
+func (p *StaticProvider) Retrieve() (Value, error) {
+	if p.Value.IsEmpty() {
+		return Value{}, ErrNoCredentials
+	}
+
+	v := p.Value
+	v.ProviderName = "static"
+	return v, nil
+}
+
+// EnvProvider reads the TOASTATE_TOKEN, TOASTATE_API_KEY and
+// TOASTATE_API_SECRET environment variables.
+type EnvProvider struct{}
+
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+func (p *EnvProvider) Retrieve() (Value, error) {
+	v := Value{
+		Token:        os.Getenv(EnvToken),
+		APIKey:       os.Getenv(EnvAPIKey),
+		APISecret:    os.Getenv(EnvAPISecret),
+		ProviderName: "environment",
+	}
+	if v.IsEmpty() {
+		return Value{}, ErrNoCredentials
+	}
+
+	return v, nil
+}
+
+// ChainProvider returns the credentials of the first of its providers that
+// finds some.
+type ChainProvider struct {
+	Providers []Provider
+}
+
+func NewChainProvider(providers ...Provider) *ChainProvider {
+	return &ChainProvider{
+		Providers: providers,
+	}
+}
+
+// NewDefaultChain looks up the environment variables, then the shared
+// credentials file.
+func NewDefaultChain() *ChainProvider {
+	return NewChainProvider(NewEnvProvider(), NewFileProvider("", ""))
+}
+
+func (p *ChainProvider) Retrieve() (Value, error) {
+	for _, provider := range p.Providers {
+		v, err := provider.Retrieve()
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			return Value{}, err
+		}
+	}
+
+	return Value{}, fmt.Errorf("%w in the chain of %d providers", ErrNoCredentials, len(p.Providers))
+}
@@ -0,0 +1,122 @@
+package credentials_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud/credentials"
+)
+
+func TestEnvProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    credentials.Value
+		wantErr error
+	}{
+		{
+			name:    "empty",
+			wantErr: credentials.ErrNoCredentials,
+		},
+		{
+			name:    "secret alone",
+			env:     map[string]string{credentials.EnvAPISecret: "secret"},
+			wantErr: credentials.ErrNoCredentials,
+		},
+		{
+			name: "token",
+			env:  map[string]string{credentials.EnvToken: "sess_abc"},
+			want: credentials.Value{Token: "sess_abc", ProviderName: "environment"},
+		},
+		{
+			name: "api key",
+			env:  map[string]string{credentials.EnvAPIKey: "key_abc", credentials.EnvAPISecret: "secret"},
+			want: credentials.Value{APIKey: "key_abc", APISecret: "secret", ProviderName: "environment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{credentials.EnvToken, credentials.EnvAPIKey, credentials.EnvAPISecret} {
+				t.Setenv(k, tt.env[k])
+			}
+
+			got, err := credentials.NewEnvProvider().Retrieve()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Retrieve = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// errorProvider fails with err.
+type errorProvider struct {
+	err error
+}
+
+func (p errorProvider) Retrieve() (credentials.Value, error) {
+	return credentials.Value{}, p.err
+}
+
+func TestChainProvider(t *testing.T) {
+	failure := errors.New("unreadable credentials file")
+
+	tests := []struct {
+		name      string
+		providers []credentials.Provider
+		want      string
+		wantErr   error
+	}{
+		{
+			name: "first",
+			providers: []credentials.Provider{
+				credentials.NewStaticProvider("sess_first", "", ""),
+				credentials.NewStaticProvider("sess_second", "", ""),
+			},
+			want: "sess_first",
+		},
+		{
+			name: "falls through",
+			providers: []credentials.Provider{
+				credentials.NewStaticProvider("", "", ""),
+				errorProvider{credentials.ErrNoCredentials},
+				credentials.NewStaticProvider("sess_third", "", ""),
+			},
+			want: "sess_third",
+		},
+		{
+			name: "stops on errors",
+			providers: []credentials.Provider{
+				errorProvider{failure},
+				credentials.NewStaticProvider("sess_second", "", ""),
+			},
+			wantErr: failure,
+		},
+		{
+			name: "none",
+			providers: []credentials.Provider{
+				credentials.NewStaticProvider("", "", ""),
+			},
+			wantErr: credentials.ErrNoCredentials,
+		},
+		{
+			name:    "empty",
+			wantErr: credentials.ErrNoCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := credentials.NewChainProvider(tt.providers...).Retrieve()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Token != tt.want {
+				t.Errorf("Retrieve token = %q, want %q", got.Token, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package credentials
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileProvider reads a profile of the shared credentials file, which uses
+// the INI format:
+//
+//	[default]
+//	token = sess_...
+//
+//	[ci]
+//	api_key = key_...
+//	api_secret = ...
+//	api_url = https://api.staging.example.com
+//	api_version = v1
+type FileProvider struct {
+	// Filename defaults to TOASTATE_CREDENTIALS_FILE, then to
+	// ~/.toastate/credentials.
+	Filename string
+
+	// Profile defaults to TOASTATE_PROFILE, then to "default".
+	Profile string
+}
+
+func NewFileProvider(filename, profile string) *FileProvider {
+	return &FileProvider{
+		Filename: filename,
+		Profile:  profile,
+	}
+}
+
+func DefaultFilename() (string, error) {
+	if v := os.Getenv(EnvCredentialsFile); v != "" {
+		return v, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".toastate", "credentials"), nil
+}
+
+func (p *FileProvider) Retrieve() (Value, error) {
+	filename := p.Filename
+	if filename == "" {
+		var err error
+		filename, err = DefaultFilename()
+		if err != nil {
+			return Value{}, ErrNoCredentials
+		}
+	}
+
+	// A profile picked explicitly must exist, the default one may not.
+	profile, explicit := p.Profile, true
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+	if profile == "" {
+		profile, explicit = DefaultProfile, false
+	}
+
+	profiles, err := readProfiles(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return Value{}, ErrNoCredentials
+		}
+		return Value{}, err
+	}
+
+	section, ok := profiles[profile]
+	if !ok {
+		if !explicit {
+			return Value{}, ErrNoCredentials
+		}
+		return Value{}, fmt.Errorf("profile %q not found in %v", profile, filename)
+	}
+
+	v := Value{
+		Token:        section["token"],
+		APIKey:       section["api_key"],
+		APISecret:    section["api_secret"],
+		APIURL:       section["api_url"],
+		APIVersion:   section["api_version"],
+		ProviderName: "file:" + profile,
+	}
+	if v.IsEmpty() {
+		return Value{}, fmt.Errorf("%w in profile %q of %v", ErrNoCredentials, profile, filename)
+	}
+
+	return v, nil
+}
+
+// readProfiles parses an INI file into its sections.
+func readProfiles(filename string) (map[string]map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var section map[string]string
+
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("%v:%d: invalid section header", filename, lineno)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			section = profiles[name]
+			if section == nil {
+				section = map[string]string{}
+				profiles[name] = section
+			}
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("%v:%d: expected key = value", filename, lineno)
+		}
+		if section == nil {
+			return nil, fmt.Errorf("%v:%d: key outside of a profile section", filename, lineno)
+		}
+
+		key := strings.TrimSpace(line[:i])
+		value := strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+		section[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
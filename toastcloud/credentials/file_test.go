@@ -0,0 +1,162 @@
+package credentials_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud/credentials"
+)
+
+const credentialsFile = `# shared credentials
+[default]
+token = sess_default
+
+[ci]
+api_key = "key_ci"
+api_secret = 'secret with spaces'
+api_url = https://api.staging.test
+api_version = v2
+
+; a profile without credentials
+[empty]
+api_url = https://api.test
+`
+
+func writeCredentials(t *testing.T, content string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestFileProvider(t *testing.T) {
+	filename := writeCredentials(t, credentialsFile)
+
+	tests := []struct {
+		name    string
+		profile string
+		env     string
+		want    credentials.Value
+		wantErr error
+		// errContains is checked on errors other than wantErr.
+		errContains string
+	}{
+		{
+			name: "default profile",
+			want: credentials.Value{Token: "sess_default", ProviderName: "file:default"},
+		},
+		{
+			name:    "named profile with quotes",
+			profile: "ci",
+			want: credentials.Value{
+				APIKey:       "key_ci",
+				APISecret:    "secret with spaces",
+				APIURL:       "https://api.staging.test",
+				APIVersion:   "v2",
+				ProviderName: "file:ci",
+			},
+		},
+		{
+			name: "profile from the environment",
+			env:  "ci",
+			want: credentials.Value{
+				APIKey:       "key_ci",
+				APISecret:    "secret with spaces",
+				APIURL:       "https://api.staging.test",
+				APIVersion:   "v2",
+				ProviderName: "file:ci",
+			},
+		},
+		{
+			name:        "missing explicit profile",
+			profile:     "prod",
+			errContains: `profile "prod" not found`,
+		},
+		{
+			name:    "profile without credentials",
+			profile: "empty",
+			wantErr: credentials.ErrNoCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(credentials.EnvProfile, tt.env)
+
+			got, err := credentials.NewFileProvider(filename, tt.profile).Retrieve()
+			switch {
+			case tt.errContains != "":
+				if err == nil || errors.Is(err, credentials.ErrNoCredentials) || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Retrieve error = %v, want %q", err, tt.errContains)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("Retrieve error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Retrieve = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderMissing(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	noDefault := writeCredentials(t, "[ci]\ntoken = sess_ci\n")
+
+	tests := []struct {
+		name     string
+		filename string
+		profile  string
+		// fallThrough is set when a ChainProvider moves on to its next
+		// provider.
+		fallThrough bool
+	}{
+		{"missing default profile", noDefault, "", true},
+		{"missing file", missing, "", true},
+		{"missing explicit profile", noDefault, "prod", false},
+		{"missing file with an explicit profile", missing, "ci", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(credentials.EnvProfile, "")
+
+			_, err := credentials.NewFileProvider(tt.filename, tt.profile).Retrieve()
+			if err == nil {
+				t.Fatal("Retrieve succeeded")
+			}
+			if errors.Is(err, credentials.ErrNoCredentials) != tt.fallThrough {
+				t.Errorf("Retrieve error = %v, want ErrNoCredentials %v", err, tt.fallThrough)
+			}
+		})
+	}
+}
+
+func TestFileProviderSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unclosed section", "[default\ntoken = sess_abc\n", ":1: invalid section header"},
+		{"no value", "[default]\ntoken\n", ":2: expected key = value"},
+		{"key outside of a section", "token = sess_abc\n", ":1: key outside of a profile section"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := writeCredentials(t, tt.content)
+
+			_, err := credentials.NewFileProvider(filename, "").Retrieve()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Retrieve error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
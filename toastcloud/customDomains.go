@@ -1,7 +1,7 @@
 package toastcloud
 
 import (
-	"fmt"
+	"context"
 
 	"github.com/toastate/toastate-sdk-go/common/models"
 )
@@ -29,18 +29,26 @@ type createCustomDomainResponse struct {
 }
 
 func (sess *Session) CreateCustomDomain(input *CreateCustomDomainInput) (*CreateCustomDomainOutput, error) {
+	return sess.CreateCustomDomainWithContext(context.Background(), input)
+}
+
+func (sess *Session) CreateCustomDomainWithContext(ctx context.Context, input *CreateCustomDomainInput) (*CreateCustomDomainOutput, error) {
 	resp := &createCustomDomainResponse{}
 
-	apierr, err := sess.client.AuthedPost("/customdomain", input, resp)
+	if err := validateLinkedToasters(input.LinkedToaster); err != nil {
+		return nil, err
+	}
+
+	apierr, err := sess.client.AuthedPost(ctx, "/customdomain", input, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &CreateCustomDomainOutput{
@@ -65,22 +73,26 @@ type verifyCustomDomainResponse struct {
 }
 
 func (sess *Session) VerifyCustomDomain(input *VerifyCustomDomainInput) (*VerifyCustomDomainOutput, error) {
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the custom domain to update")
+	return sess.VerifyCustomDomainWithContext(context.Background(), input)
+}
+
+func (sess *Session) VerifyCustomDomainWithContext(ctx context.Context, input *VerifyCustomDomainInput) (*VerifyCustomDomainOutput, error) {
+	if err := CustomDomainID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
 	resp := &verifyCustomDomainResponse{}
 
-	apierr, err := sess.client.AuthedPost("/customdomain/verify/"+input.ID, nil, resp)
+	apierr, err := sess.client.AuthedPost(ctx, "/customdomain/verify/"+input.ID, nil, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &VerifyCustomDomainOutput{
@@ -116,8 +128,15 @@ type updateCustomDomainResponse struct {
 }
 
 func (sess *Session) UpdateCustomDomain(input *UpdateCustomDomainInput) (*UpdateCustomDomainOutput, error) {
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the custom domain to update")
+	return sess.UpdateCustomDomainWithContext(context.Background(), input)
+}
+
+func (sess *Session) UpdateCustomDomainWithContext(ctx context.Context, input *UpdateCustomDomainInput) (*UpdateCustomDomainOutput, error) {
+	if err := CustomDomainID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateLinkedToasters(input.LinkedToaster); err != nil {
+		return nil, err
 	}
 
 	resp := &updateCustomDomainResponse{}
@@ -126,16 +145,16 @@ func (sess *Session) UpdateCustomDomain(input *UpdateCustomDomainInput) (*Update
 		LinkedToaster: input.LinkedToaster,
 	}
 
-	apierr, err := sess.client.AuthedPut("/customdomain/"+input.ID, req, resp)
+	apierr, err := sess.client.AuthedPut(ctx, "/customdomain/"+input.ID, req, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &UpdateCustomDomainOutput{
@@ -159,18 +178,22 @@ type listCustomDomainsResponse struct {
 }
 
 func (sess *Session) ListCustomDomains(input *ListCustomDomainsInput) (*ListCustomDomainsOutput, error) {
+	return sess.ListCustomDomainsWithContext(context.Background(), input)
+}
+
+func (sess *Session) ListCustomDomainsWithContext(ctx context.Context, input *ListCustomDomainsInput) (*ListCustomDomainsOutput, error) {
 	resp := &listCustomDomainsResponse{}
 
-	apierr, err := sess.client.AuthedGet("/customdomain/list", resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/customdomain/list", resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &ListCustomDomainsOutput{
@@ -198,22 +221,26 @@ type getCustomDomainResponse struct {
 }
 
 func (sess *Session) GetCustomDomain(input *GetCustomDomainInput) (*GetCustomDomainOutput, error) {
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the custom domain to get")
+	return sess.GetCustomDomainWithContext(context.Background(), input)
+}
+
+func (sess *Session) GetCustomDomainWithContext(ctx context.Context, input *GetCustomDomainInput) (*GetCustomDomainOutput, error) {
+	if err := CustomDomainID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
 	resp := &getCustomDomainResponse{}
 
-	apierr, err := sess.client.AuthedGet("/customdomain/"+input.ID, resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/customdomain/"+input.ID, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &GetCustomDomainOutput{
@@ -236,23 +263,38 @@ type deleteCustomDomainResponse struct {
 }
 
 func (sess *Session) DeleteCustomDomain(input *DeleteCustomDomainInput) (*DeleteCustomDomainOutput, error) {
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the custom domain to delete")
+	return sess.DeleteCustomDomainWithContext(context.Background(), input)
+}
+
+func (sess *Session) DeleteCustomDomainWithContext(ctx context.Context, input *DeleteCustomDomainInput) (*DeleteCustomDomainOutput, error) {
+	if err := CustomDomainID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
 	resp := &deleteCustomDomainResponse{}
 
-	apierr, err := sess.client.AuthedDelete("/customdomain/"+input.ID, nil, resp)
+	apierr, err := sess.client.AuthedDelete(ctx, "/customdomain/"+input.ID, nil, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &DeleteCustomDomainOutput{}, nil
 }
+
+// validateLinkedToasters checks the toaster IDs of a domain to toaster ID
+// mapping.
+func validateLinkedToasters(linked map[string]string) error {
+	for _, id := range linked {
+		if err := ToasterID(id).Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
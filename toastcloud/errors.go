@@ -0,0 +1,109 @@
+package toastcloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/internal/apiclient"
+)
+
+var (
+	// ErrUnexpectedFailure is returned when the API answers with a 200 HTTP
+	// status code but reports that the operation did not succeed.
+	ErrUnexpectedFailure = errors.New("The API returned a failure with a 200 HTTP status code which should not happen")
+
+	// ErrEmptyResponse is returned when the API reports a success but did not
+	// send back the data the call was expected to return.
+	ErrEmptyResponse = errors.New("The request was successfull but the remote API returned an empty body")
+
+	// ErrInvalidCredential is returned when a credential does not have the
+	// prefix of the authentication scheme it is used with.
+	ErrInvalidCredential = errors.New("invalid authentication")
+
+	// Errors returned when joining an execution fails.
+	ErrJoinWindowExpired  = errors.New("the execution is no longer joinable")
+	ErrJoinerLimitReached = errors.New("the execution reached its maximum number of concurrent joiners")
+	ErrExecutionNotFound  = errors.New("the execution does not exist or has ended")
+	ErrToasterDraining    = errors.New("the toaster is draining and accepts no new joiners")
+
+	// ErrBuildFailed is returned by WaitForBuild when the build failed.
+	ErrBuildFailed = errors.New("the build of the toaster failed")
+
+	// ErrLogStreamClosed is returned when reading a LogStream after Close.
+	ErrLogStreamClosed = errors.New("the log stream is closed")
+)
+
+// APIError is returned when the Toastate API answers with a non 200 HTTP
+// status code. Use errors.As to inspect it, or the Is* helpers below.
+type APIError struct {
+	Status    int
+	Code      string
+	Message   string
+	RequestID string
+	Body      []byte
+
+	// RetryAfter is how long the API asked to wait before trying again,
+	// mostly set on rate limited requests.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("APIERROR: status: %v; code: %v; message: %v; request id: %v", e.Status, e.Code, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("APIERROR: status: %v; code: %v; message: %v", e.Status, e.Code, e.Message)
+}
+
+// Is makes errors.Is(err, &APIError{Status: 404}) work: a target matches when
+// its non-zero Status and non-empty Code are equal to the ones of e.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	if t.Status != 0 && t.Status != e.Status {
+		return false
+	}
+	if t.Code != "" && t.Code != e.Code {
+		return false
+	}
+	return t.Status != 0 || t.Code != ""
+}
+
+func newAPIError(e *apiclient.Error) error {
+	return &APIError{
+		Status:     e.Status,
+		Code:       e.Code,
+		Message:    e.Message,
+		RequestID:  e.RequestID,
+		Body:       e.Body,
+		RetryAfter: e.RetryAfter,
+	}
+}
+
+func hasStatus(err error, status int) bool {
+	var apierr *APIError
+	return errors.As(err, &apierr) && apierr.Status == status
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+func IsBillingRequired(err error) bool {
+	return hasStatus(err, http.StatusPaymentRequired)
+}
@@ -0,0 +1,70 @@
+package toastcloud_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault *toastcloudtest.Fault
+		// unauthenticated sends the call without credentials.
+		unauthenticated bool
+		check           func(err error) bool
+	}{
+		{
+			name:  "not found",
+			fault: &toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusNotFound, Code: "toaster_not_found"},
+			check: toastcloud.IsNotFound,
+		},
+		{
+			name:  "typed error code",
+			fault: &toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusForbidden, Code: "not_owner"},
+			check: func(err error) bool { return errors.Is(err, &toastcloud.APIError{Code: "not_owner"}) },
+		},
+		{
+			name:  "message",
+			fault: &toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusBadRequest, Code: "invalid_name", Message: "the name is too long"},
+			check: func(err error) bool {
+				var apierr *toastcloud.APIError
+				return errors.As(err, &apierr) && apierr.Status == http.StatusBadRequest && apierr.Message == "the name is too long"
+			},
+		},
+		{
+			name:            "unauthenticated",
+			unauthenticated: true,
+			check:           toastcloud.IsUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			toaster := srv.AddToaster(toasterModel("errors"), nil)
+			if tt.unauthenticated {
+				sess = srv.UnauthenticatedSession()
+			}
+			if tt.fault != nil {
+				srv.InjectFault(*tt.fault)
+			}
+
+			err := getToaster(sess, toaster.ID)
+			if !tt.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if n := len(srv.RequestsTo("GET", "/toaster/"+toaster.ID)); n != 1 {
+				t.Errorf("%d requests, want 1", n)
+			}
+		})
+	}
+}
+
+func getToaster(sess *toastcloud.Session, id string) error {
+	_, err := sess.GetToaster(&toastcloud.GetToasterInput{ID: id})
+	return err
+}
@@ -0,0 +1,600 @@
+package toastcloud
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/internal/apiclient"
+)
+
+type ExecuteToasterInput struct {
+	// ID of the toaster to call, OR
+	ID string
+	// Domain it is served at, e.g. CreateToasterOutput.Domain or a custom
+	// domain. A scheme can be given, the one of the toaster domain of the
+	// session is used otherwise.
+	Domain string
+
+	// Method defaults to GET.
+	Method string
+	Path   string
+	Header http.Header
+	Body   io.Reader
+
+	// ForceNewExecution makes the request start a new, isolated instance of
+	// the toaster instead of joining a warm one. Such executions have IDs
+	// prefixed by ExecutionForcedExeIDPrefix.
+	ForceNewExecution bool
+
+	// JoinExecutionID routes the request to a running execution, as long as
+	// it is within the JoinableForSec window of the toaster and has less than
+	// MaxConcurrentJoiners joiners. Forced executions can not be joined.
+	JoinExecutionID string
+	// StartNewIfUnjoinable serves the request with a new execution when the
+	// join fails, instead of returning an error. JoinStatus then tells why.
+	StartNewIfUnjoinable bool
+}
+
+type JoinStatus string
+
+const (
+	// JoinStatusNone is the status of requests that did not ask to join.
+	JoinStatusNone     JoinStatus = ""
+	JoinStatusJoined   JoinStatus = "joined"
+	JoinStatusExpired  JoinStatus = "expired"
+	JoinStatusFull     JoinStatus = "full"
+	JoinStatusNotFound JoinStatus = "not_found"
+	JoinStatusDraining JoinStatus = "draining"
+)
+
+func (s JoinStatus) err() error {
+	switch s {
+	case JoinStatusExpired:
+		return ErrJoinWindowExpired
+	case JoinStatusFull:
+		return ErrJoinerLimitReached
+	case JoinStatusNotFound:
+		return ErrExecutionNotFound
+	case JoinStatusDraining:
+		return ErrToasterDraining
+	default:
+		return nil
+	}
+}
+
+type ExecutionKind int
+
+const (
+	ExecutionKindUnknown ExecutionKind = iota
+	// ExecutionKindNormal executions (ex_) may be joined by other requests.
+	ExecutionKindNormal
+	// ExecutionKindForced executions (fex_) were started by a request with
+	// ForceNewExecution set.
+	ExecutionKindForced
+)
+
+func (k ExecutionKind) String() string {
+	switch k {
+	case ExecutionKindNormal:
+		return "normal"
+	case ExecutionKindForced:
+		return "forced"
+	default:
+		return "unknown"
+	}
+}
+
+// ExecutionKindOf returns the kind of an execution from the prefix of its ID.
+func ExecutionKindOf(exeID string) ExecutionKind {
+	switch {
+	case strings.HasPrefix(exeID, ExecutionForcedExeIDPrefix):
+		return ExecutionKindForced
+	case strings.HasPrefix(exeID, ExecutionIDPrefix):
+		return ExecutionKindNormal
+	default:
+		return ExecutionKindUnknown
+	}
+}
+
+type ExecutionTiming struct {
+	Start time.Time
+	// TimeToFirstByte is the time it took to receive the response headers.
+	TimeToFirstByte time.Duration
+	// Duration is the time it took to receive the whole response.
+	Duration time.Duration
+}
+
+type ExecuteToasterOutput struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// ExecutionID identifies the execution that served the request, to be
+	// used with GetToasterLogs.
+	ExecutionID   string
+	ExecutionKind ExecutionKind
+	Timing        ExecutionTiming
+
+	// JoinStatus is JoinStatusJoined when the request joined the execution
+	// given in JoinExecutionID.
+	JoinStatus JoinStatus
+}
+
+func (sess *Session) ExecuteToaster(input *ExecuteToasterInput) (*ExecuteToasterOutput, error) {
+	return sess.ExecuteToasterWithContext(context.Background(), input)
+}
+
+// ExecuteToasterWithContext sends an HTTP request to a toaster. Responses
+// are returned whatever their status code, since they come from the code of
+// the toaster itself.
+func (sess *Session) ExecuteToasterWithContext(ctx context.Context, input *ExecuteToasterInput) (*ExecuteToasterOutput, error) {
+	if input.JoinExecutionID != "" {
+		if input.ForceNewExecution {
+			return nil, fmt.Errorf("a request can not both join an execution and force a new one")
+		}
+		if err := ExecutionID(input.JoinExecutionID).Validate(); err != nil {
+			return nil, err
+		}
+		if ExecutionKindOf(input.JoinExecutionID) != ExecutionKindNormal {
+			return nil, fmt.Errorf("%q is not the ID of a joinable execution", input.JoinExecutionID)
+		}
+		if input.StartNewIfUnjoinable && input.Body != nil {
+			// The body may have to be sent twice.
+			b, err := io.ReadAll(input.Body)
+			if err != nil {
+				return nil, err
+			}
+			in := *input
+			in.Body = bytes.NewReader(b)
+			input = &in
+		}
+	}
+
+	out, err := sess.executeToaster(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if joinErr := out.JoinStatus.err(); joinErr != nil {
+		if !input.StartNewIfUnjoinable {
+			return nil, joinErr
+		}
+
+		retry := *input
+		retry.JoinExecutionID = ""
+		if r, ok := input.Body.(*bytes.Reader); ok {
+			r.Seek(0, io.SeekStart)
+		}
+		status := out.JoinStatus
+		out, err = sess.executeToaster(ctx, &retry)
+		if err != nil {
+			return nil, err
+		}
+		out.JoinStatus = status
+	}
+
+	return out, nil
+}
+
+func (sess *Session) executeToaster(ctx context.Context, input *ExecuteToasterInput) (*ExecuteToasterOutput, error) {
+	req, err := sess.newToasterRequest(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	response, err := sess.client.Do(req, false)
+	if err != nil {
+		return nil, err
+	}
+	ttfb := time.Since(start)
+
+	b, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	exeID := response.Header.Get(ExecutionIDHeader)
+	return &ExecuteToasterOutput{
+		StatusCode:    response.StatusCode,
+		Header:        response.Header,
+		Body:          b,
+		ExecutionID:   exeID,
+		ExecutionKind: ExecutionKindOf(exeID),
+		Timing: ExecutionTiming{
+			Start:           start,
+			TimeToFirstByte: ttfb,
+			Duration:        time.Since(start),
+		},
+		JoinStatus: JoinStatus(response.Header.Get(JoinStatusHeader)),
+	}, nil
+}
+
+func (sess *Session) newToasterRequest(ctx context.Context, input *ExecuteToasterInput) (*http.Request, error) {
+	base, err := sess.toasterURL(input.ID, input.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	method := input.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	path := input.Path
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, base+path, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range input.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if input.ForceNewExecution {
+		req.Header.Set(ForceNewExecutionHeader, "true")
+	}
+	if input.JoinExecutionID != "" {
+		req.Header.Set(ExecutionIDHeader, input.JoinExecutionID)
+	}
+
+	return req, nil
+}
+
+// toasterURL returns the scheme and host a toaster is reached at.
+func (sess *Session) toasterURL(id, domain string) (string, error) {
+	switch {
+	case domain != "":
+		domain = strings.TrimSuffix(domain, "/")
+		if strings.Contains(domain, "://") {
+			return domain, nil
+		}
+		return sess.toasterScheme + "://" + domain, nil
+	case id != "":
+		if err := ToasterID(id).Validate(); err != nil {
+			return "", err
+		}
+		return sess.toasterScheme + "://" + id + "." + sess.toasterDomain, nil
+	default:
+		return "", fmt.Errorf("you did not provide the ID or the domain of the Toaster")
+	}
+}
+
+type JoinExecutionInput struct {
+	// ID or Domain of the toaster running the execution.
+	ID     string
+	Domain string
+
+	ExecutionID string
+
+	Method string
+	Path   string
+	Header http.Header
+	Body   io.Reader
+
+	StartNewIfUnjoinable bool
+}
+
+func (sess *Session) JoinExecution(input *JoinExecutionInput) (*ExecuteToasterOutput, error) {
+	return sess.JoinExecutionWithContext(context.Background(), input)
+}
+
+// JoinExecutionWithContext sends a request to a running execution, so that
+// it is served by the same instance as the request that started it. Unless
+// StartNewIfUnjoinable is set, it fails with ErrJoinWindowExpired,
+// ErrJoinerLimitReached, ErrExecutionNotFound or ErrToasterDraining when the
+// execution can not be joined.
+func (sess *Session) JoinExecutionWithContext(ctx context.Context, input *JoinExecutionInput) (*ExecuteToasterOutput, error) {
+	if err := ExecutionID(input.ExecutionID).Validate(); err != nil {
+		return nil, err
+	}
+
+	return sess.ExecuteToasterWithContext(ctx, &ExecuteToasterInput{
+		ID:                   input.ID,
+		Domain:               input.Domain,
+		Method:               input.Method,
+		Path:                 input.Path,
+		Header:               input.Header,
+		Body:                 input.Body,
+		JoinExecutionID:      input.ExecutionID,
+		StartNewIfUnjoinable: input.StartNewIfUnjoinable,
+	})
+}
+
+type ListExecutionsInput struct {
+	ID string `json:"id,omitempty"`
+
+	// Only list executions started within [From, To), when set.
+	From time.Time `json:"from,omitempty"`
+	To   time.Time `json:"to,omitempty"`
+
+	// Status is one of the models.ExecutionStatus* values.
+	Status string `json:"status,omitempty"`
+
+	// Kind only lists normal or forced executions, when set.
+	Kind ExecutionKind `json:"kind,omitempty"`
+
+	// Limit is the maximum number of executions per page.
+	Limit int `json:"limit,omitempty"`
+	// NextToken is the NextToken of the previous page.
+	NextToken string `json:"next_token,omitempty"`
+}
+
+type ListExecutionsOutput struct {
+	Executions []models.Execution `json:"executions,omitempty"`
+	// NextToken is empty on the last page.
+	NextToken string `json:"next_token,omitempty"`
+}
+
+type listExecutionsResponse struct {
+	Success    bool               `json:"success"`
+	Executions []models.Execution `json:"executions,omitempty"`
+	NextToken  string             `json:"next_token,omitempty"`
+}
+
+func (sess *Session) ListExecutions(input *ListExecutionsInput) (*ListExecutionsOutput, error) {
+	return sess.ListExecutionsWithContext(context.Background(), input)
+}
+
+func (sess *Session) ListExecutionsWithContext(ctx context.Context, input *ListExecutionsInput) (*ListExecutionsOutput, error) {
+	resp := &listExecutionsResponse{}
+
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if !input.From.IsZero() {
+		query.Set("from", input.From.UTC().Format(time.RFC3339))
+	}
+	if !input.To.IsZero() {
+		query.Set("to", input.To.UTC().Format(time.RFC3339))
+	}
+	if input.Status != "" {
+		query.Set("status", input.Status)
+	}
+	switch input.Kind {
+	case ExecutionKindNormal:
+		query.Set("forced", "false")
+	case ExecutionKindForced:
+		query.Set("forced", "true")
+	}
+	if input.Limit > 0 {
+		query.Set("limit", strconv.Itoa(input.Limit))
+	}
+	if input.NextToken != "" {
+		query.Set("next_token", input.NextToken)
+	}
+
+	path := "/toaster/executions/" + input.ID
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	apierr, err := sess.client.AuthedGet(ctx, path, resp)
+	if err != nil {
+		return nil, err
+	}
+	if apierr != nil {
+		return nil, newAPIError(apierr)
+	}
+
+	if !resp.Success {
+		return nil, ErrUnexpectedFailure
+	}
+
+	return &ListExecutionsOutput{
+		Executions: resp.Executions,
+		NextToken:  resp.NextToken,
+	}, nil
+}
+
+// ListExecutionsPages calls fn with every page of executions, until fn
+// returns false or the last page, for which lastPage is true, is reached.
+func (sess *Session) ListExecutionsPages(input *ListExecutionsInput, fn func(page *ListExecutionsOutput, lastPage bool) bool) error {
+	return sess.ListExecutionsPagesWithContext(context.Background(), input, fn)
+}
+
+func (sess *Session) ListExecutionsPagesWithContext(ctx context.Context, input *ListExecutionsInput, fn func(page *ListExecutionsOutput, lastPage bool) bool) error {
+	in := *input
+	for {
+		page, err := sess.ListExecutionsWithContext(ctx, &in)
+		if err != nil {
+			return err
+		}
+
+		lastPage := page.NextToken == ""
+		if !fn(page, lastPage) || lastPage {
+			return nil
+		}
+		in.NextToken = page.NextToken
+	}
+}
+
+type GetExecutionInput struct {
+	ID string `json:"id,omitempty"`
+	// ExeID is the ID of a normal (ex_) or forced (fex_) execution.
+	ExeID string `json:"exe_id,omitempty"`
+}
+
+type GetExecutionOutput struct {
+	Execution *models.Execution `json:"execution,omitempty"`
+}
+
+type getExecutionResponse struct {
+	Success   bool              `json:"success"`
+	Execution *models.Execution `json:"execution,omitempty"`
+}
+
+func (sess *Session) GetExecution(input *GetExecutionInput) (*GetExecutionOutput, error) {
+	return sess.GetExecutionWithContext(context.Background(), input)
+}
+
+func (sess *Session) GetExecutionWithContext(ctx context.Context, input *GetExecutionInput) (*GetExecutionOutput, error) {
+	resp := &getExecutionResponse{}
+
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+	if err := ExecutionID(input.ExeID).Validate(); err != nil {
+		return nil, err
+	}
+
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/execution/"+input.ID+"/"+input.ExeID, resp)
+	if err != nil {
+		return nil, err
+	}
+	if apierr != nil {
+		return nil, newAPIError(apierr)
+	}
+
+	if !resp.Success {
+		return nil, ErrUnexpectedFailure
+	}
+
+	if resp.Execution == nil {
+		return nil, ErrEmptyResponse
+	}
+
+	return &GetExecutionOutput{
+		Execution: resp.Execution,
+	}, nil
+}
+
+// DefaultExecutionPollInterval is how often StopExecution, KillExecution and
+// DrainToaster check whether they are done, unless told otherwise.
+const DefaultExecutionPollInterval = time.Second
+
+type StopExecutionInput struct {
+	ID    string `json:"id,omitempty"`
+	ExeID string `json:"exe_id,omitempty"`
+
+	// GracePeriod is how long the execution is given to exit after being
+	// signaled before it is killed. The API default is used when zero.
+	GracePeriod time.Duration `json:"-"`
+
+	// PollInterval is how often the execution is checked while waiting for
+	// it to end. Defaults to DefaultExecutionPollInterval.
+	PollInterval time.Duration `json:"-"`
+}
+
+type StopExecutionOutput struct {
+	// Execution is the final state of the execution.
+	Execution *models.Execution `json:"execution,omitempty"`
+}
+
+type stopExecutionRequest struct {
+	GracePeriodSec int `json:"grace_period_sec,omitempty"`
+}
+
+type stopExecutionResponse struct {
+	Success   bool              `json:"success"`
+	Execution *models.Execution `json:"execution,omitempty"`
+}
+
+func (sess *Session) StopExecution(input *StopExecutionInput) (*StopExecutionOutput, error) {
+	return sess.StopExecutionWithContext(context.Background(), input)
+}
+
+// StopExecutionWithContext signals an execution to exit, and kills it if it
+// is still running after GracePeriod. It returns once the execution ended.
+func (sess *Session) StopExecutionWithContext(ctx context.Context, input *StopExecutionInput) (*StopExecutionOutput, error) {
+	req := &stopExecutionRequest{
+		GracePeriodSec: int((input.GracePeriod + time.Second - 1) / time.Second),
+	}
+
+	exe, err := sess.endExecution(ctx, "stop", input.ID, input.ExeID, req, input.PollInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StopExecutionOutput{
+		Execution: exe,
+	}, nil
+}
+
+type KillExecutionInput struct {
+	ID    string `json:"id,omitempty"`
+	ExeID string `json:"exe_id,omitempty"`
+
+	// PollInterval is how often the execution is checked while waiting for
+	// it to end. Defaults to DefaultExecutionPollInterval.
+	PollInterval time.Duration `json:"-"`
+}
+
+type KillExecutionOutput struct {
+	// Execution is the final state of the execution.
+	Execution *models.Execution `json:"execution,omitempty"`
+}
+
+func (sess *Session) KillExecution(input *KillExecutionInput) (*KillExecutionOutput, error) {
+	return sess.KillExecutionWithContext(context.Background(), input)
+}
+
+// KillExecutionWithContext kills an execution without letting it exit
+// gracefully. It returns once the execution ended.
+func (sess *Session) KillExecutionWithContext(ctx context.Context, input *KillExecutionInput) (*KillExecutionOutput, error) {
+	exe, err := sess.endExecution(ctx, "kill", input.ID, input.ExeID, nil, input.PollInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KillExecutionOutput{
+		Execution: exe,
+	}, nil
+}
+
+// endExecution asks the API to stop or kill an execution, then polls it
+// until it ended.
+func (sess *Session) endExecution(ctx context.Context, verb, id, exeID string, body interface{}, interval time.Duration) (*models.Execution, error) {
+	resp := &stopExecutionResponse{}
+
+	if err := ToasterID(id).Validate(); err != nil {
+		return nil, err
+	}
+	if err := ExecutionID(exeID).Validate(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultExecutionPollInterval
+	}
+
+	apierr, err := sess.client.AuthedPost(ctx, "/toaster/execution/"+verb+"/"+id+"/"+exeID, body, resp)
+	if err != nil {
+		return nil, err
+	}
+	if apierr != nil {
+		return nil, newAPIError(apierr)
+	}
+
+	if !resp.Success {
+		return nil, ErrUnexpectedFailure
+	}
+
+	exe := resp.Execution
+	for exe == nil || exe.EndTime.IsZero() {
+		err = apiclient.SleepContext(ctx, interval)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := sess.GetExecutionWithContext(ctx, &GetExecutionInput{ID: id, ExeID: exeID})
+		if err != nil {
+			return nil, err
+		}
+		exe = out.Execution
+	}
+
+	return exe, nil
+}
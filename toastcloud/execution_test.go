@@ -0,0 +1,241 @@
+package toastcloud_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+func TestExecuteToaster(t *testing.T) {
+	srv, sess := newTestServer(t)
+	toaster := srv.AddToaster(toasterModel("execute"), nil)
+
+	out, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID, Method: "POST", Path: "/hello", Body: strings.NewReader("ping")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != 200 || out.ExecutionKind != toastcloud.ExecutionKindNormal || out.ExecutionID == "" {
+		t.Fatalf("ExecuteToaster = %d, kind %v, execution %q", out.StatusCode, out.ExecutionKind, out.ExecutionID)
+	}
+
+	req := srv.AssertRequested(t, "POST", "/hello")
+	if req.Host == "" || string(req.Body) != "ping" {
+		t.Errorf("request to the toaster = host %q, body %q", req.Host, req.Body)
+	}
+}
+
+func TestJoinExecution(t *testing.T) {
+	tests := []struct {
+		name          string
+		joinableFor   int
+		end           bool
+		startNew      bool
+		wantErr       error
+		wantSameExeID bool
+	}{
+		{name: "joined", joinableFor: 60, wantSameExeID: true},
+		{name: "ended", joinableFor: 60, end: true, wantErr: toastcloud.ErrExecutionNotFound},
+		{name: "window expired", joinableFor: 0, wantErr: toastcloud.ErrJoinWindowExpired},
+		{name: "new execution when unjoinable", joinableFor: 60, end: true, startNew: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			toaster := srv.AddToaster(models.Toaster{ExeCmd: []string{"./app"}, JoinableForSec: tt.joinableFor, MaxConcurrentJoiners: 10}, nil)
+
+			started, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.end {
+				srv.EndExecution(started.ExecutionID)
+			}
+
+			joined, err := sess.JoinExecution(&toastcloud.JoinExecutionInput{
+				ID:                   toaster.ID,
+				ExecutionID:          started.ExecutionID,
+				Path:                 "/again",
+				StartNewIfUnjoinable: tt.startNew,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("JoinExecution error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (joined.ExecutionID == started.ExecutionID) != tt.wantSameExeID {
+				t.Errorf("joined execution %s, started %s", joined.ExecutionID, started.ExecutionID)
+			}
+			srv.AssertRequested(t, "GET", "/again")
+		})
+	}
+}
+
+func TestListExecutions(t *testing.T) {
+	srv, sess := newTestServer(t)
+	toaster := srv.AddToaster(toasterModel("list"), nil)
+
+	var ids []string
+	for _, force := range []bool{false, false, true} {
+		out, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID, ForceNewExecution: force})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, out.ExecutionID)
+	}
+	srv.EndExecution(ids[0])
+
+	tests := []struct {
+		name  string
+		input toastcloud.ListExecutionsInput
+		// want are indexes in ids, most recent first.
+		want []int
+	}{
+		{name: "all", want: []int{2, 1, 0}},
+		{name: "succeeded", input: toastcloud.ListExecutionsInput{Status: models.ExecutionStatusSucceeded}, want: []int{0}},
+		{name: "forced", input: toastcloud.ListExecutionsInput{Kind: toastcloud.ExecutionKindForced}, want: []int{2}},
+		{name: "normal", input: toastcloud.ListExecutionsInput{Kind: toastcloud.ExecutionKindNormal}, want: []int{1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			input.ID = toaster.ID
+			out, err := sess.ListExecutions(&input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want []string
+			for _, exe := range out.Executions {
+				got = append(got, exe.ID)
+			}
+			for _, i := range tt.want {
+				want = append(want, ids[i])
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("ListExecutions = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Pages follow each other with NextToken.
+	var paged []string
+	input := &toastcloud.ListExecutionsInput{ID: toaster.ID, Limit: 2}
+	for page := 0; page < 3; page++ {
+		out, err := sess.ListExecutions(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, exe := range out.Executions {
+			paged = append(paged, exe.ID)
+		}
+		if out.NextToken == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+	if len(paged) != len(ids) {
+		t.Errorf("%d executions listed in pages, want %d", len(paged), len(ids))
+	}
+
+	got, err := sess.GetExecution(&toastcloud.GetExecutionInput{ID: toaster.ID, ExeID: ids[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Execution.ID != ids[0] || got.Execution.Status != models.ExecutionStatusSucceeded || got.Execution.EndTime.IsZero() {
+		t.Errorf("GetExecution = %+v", got.Execution)
+	}
+}
+
+func TestEndExecution(t *testing.T) {
+	tests := []struct {
+		name     string
+		end      func(sess *toastcloud.Session, id, exeID string) (*models.Execution, error)
+		status   string
+		exitCode int
+	}{
+		{
+			name: "stop",
+			end: func(sess *toastcloud.Session, id, exeID string) (*models.Execution, error) {
+				out, err := sess.StopExecution(&toastcloud.StopExecutionInput{ID: id, ExeID: exeID, PollInterval: time.Millisecond})
+				if err != nil {
+					return nil, err
+				}
+				return out.Execution, nil
+			},
+			status:   models.ExecutionStatusStopped,
+			exitCode: 143,
+		},
+		{
+			name: "kill",
+			end: func(sess *toastcloud.Session, id, exeID string) (*models.Execution, error) {
+				out, err := sess.KillExecution(&toastcloud.KillExecutionInput{ID: id, ExeID: exeID, PollInterval: time.Millisecond})
+				if err != nil {
+					return nil, err
+				}
+				return out.Execution, nil
+			},
+			status:   models.ExecutionStatusKilled,
+			exitCode: 137,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			toaster := srv.AddToaster(toasterModel(tt.name), nil)
+
+			out, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exe, err := tt.end(sess, toaster.ID, out.ExecutionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exe.Status != tt.status || exe.ExitCode != tt.exitCode || exe.EndTime.IsZero() {
+				t.Errorf("execution = %+v, want %s with exit code %d", exe, tt.status, tt.exitCode)
+			}
+		})
+	}
+}
+
+func TestDrainToaster(t *testing.T) {
+	srv, sess := newTestServer(t)
+	toaster := srv.AddToaster(models.Toaster{ExeCmd: []string{"./app"}, JoinableForSec: 60}, nil)
+
+	exe, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.SetRunning(toaster.ID, 1)
+	out, err := sess.DrainToaster(&toastcloud.DrainToasterInput{ID: toaster.ID, PollInterval: time.Millisecond, Timeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Drained || out.Running != 1 {
+		t.Errorf("DrainToaster = %+v, want 1 running execution", out)
+	}
+
+	_, err = sess.JoinExecution(&toastcloud.JoinExecutionInput{ID: toaster.ID, ExecutionID: exe.ExecutionID})
+	if !errors.Is(err, toastcloud.ErrToasterDraining) {
+		t.Errorf("JoinExecution on a draining toaster = %v, want %v", err, toastcloud.ErrToasterDraining)
+	}
+
+	srv.SetRunning(toaster.ID, 0)
+	out, err = sess.DrainToaster(&toastcloud.DrainToasterInput{ID: toaster.ID, PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Drained {
+		t.Errorf("DrainToaster = %+v, want drained", out)
+	}
+}
@@ -0,0 +1,252 @@
+package toastcloud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidID is wrapped by the errors returned when an ID is empty or not
+// of the expected kind.
+var ErrInvalidID = errors.New("invalid ID")
+
+// IDKind is the kind of resource an ID refers to, as told by its prefix.
+type IDKind int
+
+const (
+	IDKindUnknown IDKind = iota
+	IDKindToaster
+	IDKindExecution
+	IDKindForcedExecution
+	IDKindSessionToken
+	IDKindAPIKey
+	IDKindBuild
+)
+
+func (k IDKind) String() string {
+	switch k {
+	case IDKindToaster:
+		return "toaster ID"
+	case IDKindExecution:
+		return "execution ID"
+	case IDKindForcedExecution:
+		return "forced execution ID"
+	case IDKindSessionToken:
+		return "session token"
+	case IDKindAPIKey:
+		return "API key"
+	case IDKindBuild:
+		return "build ID"
+	default:
+		return "unknown ID"
+	}
+}
+
+// KindOf returns the kind of id from its prefix. Custom domain IDs have no
+// prefix: their kind is IDKindUnknown.
+func KindOf(id string) IDKind {
+	switch {
+	case strings.HasPrefix(id, ToasterIDPrefix):
+		return IDKindToaster
+	case strings.HasPrefix(id, ExecutionIDPrefix):
+		return IDKindExecution
+	case strings.HasPrefix(id, ExecutionForcedExeIDPrefix):
+		return IDKindForcedExecution
+	case strings.HasPrefix(id, SessionPrefix):
+		return IDKindSessionToken
+	case strings.HasPrefix(id, APIKeyPrefix):
+		return IDKindAPIKey
+	case strings.HasPrefix(id, BuildIDPrefix):
+		return IDKindBuild
+	default:
+		return IDKindUnknown
+	}
+}
+
+// validateID checks that id is made of prefix followed by a non empty value
+// that can be used as a path segment.
+func validateID(id, prefix, name string) error {
+	if id == "" {
+		return fmt.Errorf("%w: you did not provide the %s", ErrInvalidID, name)
+	}
+	if !strings.HasPrefix(id, prefix) || !validIDValue(id[len(prefix):]) {
+		return fmt.Errorf("%w: %q is not a valid %s", ErrInvalidID, id, name)
+	}
+	return nil
+}
+
+func validIDValue(v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, r := range v {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unmarshalID unmarshals text into id once validated. Empty IDs are
+// accepted, as for omitted fields.
+func unmarshalID(text []byte, id *string, validate func(string) error) error {
+	s := string(text)
+	if s != "" {
+		if err := validate(s); err != nil {
+			return err
+		}
+	}
+	*id = s
+	return nil
+}
+
+// ToasterID is the ID of a toaster, such as "t_...".
+type ToasterID string
+
+func ParseToasterID(s string) (ToasterID, error) {
+	id := ToasterID(s)
+	return id, id.Validate()
+}
+
+func (id ToasterID) Validate() error {
+	return validateID(string(id), ToasterIDPrefix, "toaster ID")
+}
+
+func (id ToasterID) String() string {
+	return string(id)
+}
+
+func (id ToasterID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+func (id *ToasterID) UnmarshalText(text []byte) error {
+	return unmarshalID(text, (*string)(id), func(s string) error { return ToasterID(s).Validate() })
+}
+
+// ExecutionID is the ID of a normal ("ex_...") or forced ("fex_...")
+// execution.
+type ExecutionID string
+
+func ParseExecutionID(s string) (ExecutionID, error) {
+	id := ExecutionID(s)
+	return id, id.Validate()
+}
+
+func (id ExecutionID) Validate() error {
+	prefix := ExecutionIDPrefix
+	if id.Kind() == ExecutionKindForced {
+		prefix = ExecutionForcedExeIDPrefix
+	}
+	return validateID(string(id), prefix, "execution ID")
+}
+
+func (id ExecutionID) Kind() ExecutionKind {
+	return ExecutionKindOf(string(id))
+}
+
+func (id ExecutionID) String() string {
+	return string(id)
+}
+
+func (id ExecutionID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+func (id *ExecutionID) UnmarshalText(text []byte) error {
+	return unmarshalID(text, (*string)(id), func(s string) error { return ExecutionID(s).Validate() })
+}
+
+// BuildID is the ID of a build of a toaster, such as "b_...".
+type BuildID string
+
+func ParseBuildID(s string) (BuildID, error) {
+	id := BuildID(s)
+	return id, id.Validate()
+}
+
+func (id BuildID) Validate() error {
+	return validateID(string(id), BuildIDPrefix, "build ID")
+}
+
+func (id BuildID) String() string {
+	return string(id)
+}
+
+func (id BuildID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+func (id *BuildID) UnmarshalText(text []byte) error {
+	return unmarshalID(text, (*string)(id), func(s string) error { return BuildID(s).Validate() })
+}
+
+// SessionToken authenticates a session, as returned by Signin.
+type SessionToken string
+
+func ParseSessionToken(s string) (SessionToken, error) {
+	t := SessionToken(s)
+	return t, t.Validate()
+}
+
+// Validate only checks the prefix of the token: it is sent in a header, not
+// in a path, so its value is not restricted like the one of IDs. The token
+// is not part of the error.
+func (t SessionToken) Validate() error {
+	if t == "" {
+		return fmt.Errorf("%w: you did not provide the session token", ErrInvalidID)
+	}
+	if !strings.HasPrefix(string(t), SessionPrefix) || t == SessionPrefix {
+		return fmt.Errorf("%w: the session token does not start with %q", ErrInvalidID, SessionPrefix)
+	}
+	return nil
+}
+
+// String hides the token, so that it does not end up in logs.
+func (t SessionToken) String() string {
+	if t == "" {
+		return ""
+	}
+	return SessionPrefix + "..."
+}
+
+func (t SessionToken) MarshalText() ([]byte, error) {
+	return []byte(t), nil
+}
+
+func (t *SessionToken) UnmarshalText(text []byte) error {
+	return unmarshalID(text, (*string)(t), func(s string) error { return SessionToken(s).Validate() })
+}
+
+// CustomDomainID is the ID of a custom domain. Unlike other IDs, it has no
+// prefix.
+type CustomDomainID string
+
+func ParseCustomDomainID(s string) (CustomDomainID, error) {
+	id := CustomDomainID(s)
+	return id, id.Validate()
+}
+
+func (id CustomDomainID) Validate() error {
+	if err := validateID(string(id), "", "custom domain ID"); err != nil {
+		return err
+	}
+	if k := KindOf(string(id)); k != IDKindUnknown {
+		return fmt.Errorf("%w: %q is not a valid custom domain ID but has the prefix of a %s", ErrInvalidID, id, k)
+	}
+	return nil
+}
+
+func (id CustomDomainID) String() string {
+	return string(id)
+}
+
+func (id CustomDomainID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+func (id *CustomDomainID) UnmarshalText(text []byte) error {
+	return unmarshalID(text, (*string)(id), func(s string) error { return CustomDomainID(s).Validate() })
+}
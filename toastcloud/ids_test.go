@@ -0,0 +1,189 @@
+package toastcloud_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+func TestParseIDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(s string) error
+		id    string
+		valid bool
+	}{
+		{"toaster", parseToasterID, "t_abc-123", true},
+		{"toaster with dots", parseToasterID, "t_a.b_c", true},
+		{"toaster empty", parseToasterID, "", false},
+		{"toaster prefix only", parseToasterID, "t_", false},
+		{"toaster of another kind", parseToasterID, "ex_abc", false},
+		{"toaster with a slash", parseToasterID, "t_abc/../other", false},
+		{"toaster with a query", parseToasterID, "t_abc?x=1", false},
+		{"execution", parseExecutionID, "ex_abc", true},
+		{"forced execution", parseExecutionID, "fex_abc", true},
+		{"execution of another kind", parseExecutionID, "t_abc", false},
+		{"build", parseBuildID, "b_abc", true},
+		{"build of another kind", parseBuildID, "t_abc", false},
+		{"custom domain", parseCustomDomainID, "cd123", true},
+		{"custom domain with a prefix", parseCustomDomainID, "t_abc", false},
+		{"custom domain with a space", parseCustomDomainID, "cd 123", false},
+		{"session token", parseSessionToken, "sess_abc+def/ghi=", true},
+		{"session token prefix only", parseSessionToken, "sess_", false},
+		{"session token of another kind", parseSessionToken, "key_abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.parse(tt.id)
+			if (err == nil) != tt.valid {
+				t.Fatalf("parse(%q) = %v, want valid %v", tt.id, err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, toastcloud.ErrInvalidID) {
+				t.Errorf("parse(%q) = %v, want it to wrap ErrInvalidID", tt.id, err)
+			}
+		})
+	}
+}
+
+func parseToasterID(s string) error {
+	_, err := toastcloud.ParseToasterID(s)
+	return err
+}
+
+func parseExecutionID(s string) error {
+	_, err := toastcloud.ParseExecutionID(s)
+	return err
+}
+
+func parseBuildID(s string) error {
+	_, err := toastcloud.ParseBuildID(s)
+	return err
+}
+
+func parseCustomDomainID(s string) error {
+	_, err := toastcloud.ParseCustomDomainID(s)
+	return err
+}
+
+func parseSessionToken(s string) error {
+	_, err := toastcloud.ParseSessionToken(s)
+	return err
+}
+
+func TestSessionTokenHidden(t *testing.T) {
+	token, err := toastcloud.ParseSessionToken("sess_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := token.String(); strings.Contains(s, "secret") {
+		t.Errorf("String = %q, it shows the token", s)
+	}
+
+	_, err = toastcloud.ParseSessionToken("key_secret")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("ParseSessionToken error = %v, want an error not showing the token", err)
+	}
+}
+
+func TestIDUnmarshalText(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		want  toastcloud.ToasterID
+		valid bool
+	}{
+		{"valid", `{"id":"t_abc","exe_id":"fex_abc"}`, "t_abc", true},
+		{"omitted", `{}`, "", true},
+		{"empty", `{"id":""}`, "", true},
+		{"invalid", `{"id":"t_a b"}`, "", false},
+		{"other kind", `{"id":"ex_abc"}`, "", false},
+		{"invalid execution", `{"id":"t_abc","exe_id":"t_abc"}`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct {
+				ID    toastcloud.ToasterID   `json:"id"`
+				ExeID toastcloud.ExecutionID `json:"exe_id"`
+			}
+			err := json.Unmarshal([]byte(tt.json), &v)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Unmarshal = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil {
+				if !errors.Is(err, toastcloud.ErrInvalidID) {
+					t.Errorf("Unmarshal = %v, want it to wrap ErrInvalidID", err)
+				}
+				return
+			}
+			if v.ID != tt.want {
+				t.Errorf("ID = %q, want %q", v.ID, tt.want)
+			}
+
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var back struct {
+				ID toastcloud.ToasterID `json:"id"`
+			}
+			if err := json.Unmarshal(b, &back); err != nil || back.ID != v.ID {
+				t.Errorf("round trip of %s = %q, %v", b, back.ID, err)
+			}
+		})
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		id   string
+		want toastcloud.IDKind
+	}{
+		{"t_abc", toastcloud.IDKindToaster},
+		{"ex_abc", toastcloud.IDKindExecution},
+		{"fex_abc", toastcloud.IDKindForcedExecution},
+		{"sess_abc", toastcloud.IDKindSessionToken},
+		{"key_abc", toastcloud.IDKindAPIKey},
+		{"b_abc", toastcloud.IDKindBuild},
+		{"cd123", toastcloud.IDKindUnknown},
+		{"", toastcloud.IDKindUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := toastcloud.KindOf(tt.id); got != tt.want {
+			t.Errorf("KindOf(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidIDSendsNoRequest(t *testing.T) {
+	srv, sess := newTestServer(t)
+
+	calls := []func() error{
+		func() error {
+			_, err := sess.GetToaster(&toastcloud.GetToasterInput{ID: "ex_abc"})
+			return err
+		},
+		func() error {
+			_, err := sess.GetToaster(&toastcloud.GetToasterInput{ID: "t_abc/../list"})
+			return err
+		},
+		func() error {
+			_, err := sess.GetExecution(&toastcloud.GetExecutionInput{ID: "t_abc", ExeID: "b_abc"})
+			return err
+		},
+	}
+	for _, call := range calls {
+		if err := call(); !errors.Is(err, toastcloud.ErrInvalidID) {
+			t.Errorf("error = %v, want %v", err, toastcloud.ErrInvalidID)
+		}
+	}
+
+	if reqs := srv.Requests(); len(reqs) != 0 {
+		t.Errorf("%d requests sent with invalid IDs", len(reqs))
+	}
+}
@@ -0,0 +1,308 @@
+package toastcloud
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type StreamToasterLogsInput struct {
+	ID string `json:"id,omitempty"`
+	// ExeID is the ID of a normal (ex_) or forced (fex_) execution.
+	ExeID string `json:"exe_id,omitempty"`
+
+	// Offset is the number of bytes of logs to skip, e.g. LogStream.Offset
+	// of a previous stream to resume it.
+	Offset int64 `json:"offset,omitempty"`
+
+	// Follow keeps the stream open until the execution ends, delivering logs
+	// as they are produced. Otherwise the stream ends with the logs produced
+	// so far.
+	Follow bool `json:"follow,omitempty"`
+
+	// MaxReconnects is the number of times in a row the stream reconnects
+	// after losing its connection. It defaults to 5, use a negative value to
+	// never reconnect.
+	MaxReconnects int `json:"max_reconnects,omitempty"`
+}
+
+type StreamToasterLogsOutput struct {
+	Stream *LogStream
+}
+
+// LogStream reads the logs of an execution or a build as they are streamed
+// by the API, reconnecting from the last offset read when the connection is
+// lost. It is done when the execution or the build ends, or once the current
+// logs are read when not following. Close it to release the connection, it
+// can be called while Read or Lines are in use.
+type LogStream struct {
+	sess *Session
+	// ctx is cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// path is the URL of the stream, without its query.
+	path          string
+	follow        bool
+	maxReconnects int
+
+	// mu guards the fields below, it is not held while reading body.
+	mu         sync.Mutex
+	body       io.ReadCloser
+	closed     bool
+	offset     int64
+	reconnects int
+	err        error
+
+	linesOnce sync.Once
+	lines     chan LogLine
+}
+
+type LogLine struct {
+	// Offset of the line in the logs of the execution or the build.
+	Offset int64
+	Text   string
+}
+
+func (sess *Session) StreamToasterLogs(input *StreamToasterLogsInput) (*StreamToasterLogsOutput, error) {
+	return sess.StreamToasterLogsWithContext(context.Background(), input)
+}
+
+// StreamToasterLogsWithContext opens a log stream. ctx bounds the whole
+// life of the stream, not only this call.
+func (sess *Session) StreamToasterLogsWithContext(ctx context.Context, input *StreamToasterLogsInput) (*StreamToasterLogsOutput, error) {
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+	if input.ExeID != "" {
+		if err := ExecutionID(input.ExeID).Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := sess.openLogStream(ctx, "/toaster/logs/stream/"+input.ID+"/"+input.ExeID, input.Offset, input.Follow, input.MaxReconnects)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamToasterLogsOutput{
+		Stream: s,
+	}, nil
+}
+
+func (sess *Session) openLogStream(ctx context.Context, path string, offset int64, follow bool, maxReconnects int) (*LogStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &LogStream{
+		sess:          sess,
+		ctx:           ctx,
+		cancel:        cancel,
+		path:          path,
+		follow:        follow,
+		maxReconnects: maxReconnects,
+		offset:        offset,
+	}
+	if s.maxReconnects == 0 {
+		s.maxReconnects = 5
+	}
+
+	// Connect right away so that invalid IDs are reported here.
+	body, err := s.connect(offset)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.body = body
+
+	return s, nil
+}
+
+func (s *LogStream) connect(offset int64) (io.ReadCloser, error) {
+	url := s.path + "?offset=" + strconv.FormatInt(offset, 10)
+	if s.follow {
+		url += "&follow=true"
+	}
+
+	body, apierr, err := s.sess.client.AuthedStreamedGet(s.ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if apierr != nil {
+		return nil, newAPIError(apierr)
+	}
+	return body, nil
+}
+
+// Offset returns the number of bytes of logs read so far, the initial
+// offset included.
+func (s *LogStream) Offset() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// currentBody returns the body to read from, connecting again from the
+// current offset when the previous connection was lost.
+func (s *LogStream) currentBody() (io.ReadCloser, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrLogStreamClosed
+	}
+	body, offset := s.body, s.offset
+	s.mu.Unlock()
+	if body != nil {
+		return body, nil
+	}
+
+	body, err := s.connect(offset)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		if body != nil {
+			body.Close()
+		}
+		return nil, ErrLogStreamClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.body = body
+	return body, nil
+}
+
+func (s *LogStream) Read(p []byte) (int, error) {
+	for {
+		body, err := s.currentBody()
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := body.Read(p)
+
+		s.mu.Lock()
+		s.offset += int64(n)
+		if n > 0 {
+			s.reconnects = 0
+		}
+		if s.closed {
+			s.mu.Unlock()
+			if n > 0 {
+				return n, nil
+			}
+			return 0, ErrLogStreamClosed
+		}
+		if err == nil || err == io.EOF {
+			s.mu.Unlock()
+			return n, err
+		}
+
+		// The connection was lost: reconnect from the current offset.
+		body.Close()
+		s.body = nil
+		if s.ctx.Err() != nil || s.reconnects >= s.maxReconnects {
+			s.mu.Unlock()
+			return n, err
+		}
+		s.reconnects++
+		reconnects := s.reconnects
+		s.mu.Unlock()
+		if n > 0 {
+			return n, nil
+		}
+
+		timer := time.NewTimer(time.Duration(reconnects) * 500 * time.Millisecond)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			if s.isClosed() {
+				return 0, ErrLogStreamClosed
+			}
+			return 0, s.ctx.Err()
+		}
+	}
+}
+
+func (s *LogStream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Close releases the connection of the stream. A Read in progress returns,
+// and later ones return ErrLogStreamClosed.
+func (s *LogStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	s.cancel()
+	if s.body == nil {
+		return nil
+	}
+
+	err := s.body.Close()
+	s.body = nil
+	return err
+}
+
+// Lines delivers the logs line by line on a channel, closed when the stream
+// is done. Err then tells whether it ended because of an error. Lines and
+// Read must not be used together.
+func (s *LogStream) Lines() <-chan LogLine {
+	s.linesOnce.Do(func() {
+		s.lines = make(chan LogLine)
+		go s.readLines()
+	})
+	return s.lines
+}
+
+func (s *LogStream) readLines() {
+	defer close(s.lines)
+	defer s.Close()
+
+	offset := s.Offset()
+	r := bufio.NewReader(s)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			select {
+			case s.lines <- LogLine{Offset: offset, Text: line}:
+			case <-s.ctx.Done():
+				if !s.isClosed() {
+					s.setErr(s.ctx.Err())
+				}
+				return
+			}
+			offset += int64(len(line))
+		}
+		if err != nil {
+			// Closing the stream ends Lines without an error.
+			if !errors.Is(err, io.EOF) && !errors.Is(err, ErrLogStreamClosed) {
+				s.setErr(err)
+			}
+			return
+		}
+	}
+}
+
+func (s *LogStream) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Err returns the error that ended the stream of Lines, if any.
+func (s *LogStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,131 @@
+package toastcloud_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+func TestStreamToasterLogs(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		want   []toastcloud.LogLine
+	}{
+		{
+			name: "from the start",
+			want: []toastcloud.LogLine{{Offset: 0, Text: "first\n"}, {Offset: 6, Text: "second\n"}},
+		},
+		{
+			name:   "from an offset",
+			offset: 6,
+			want:   []toastcloud.LogLine{{Offset: 6, Text: "second\n"}},
+		},
+		{
+			name:   "past the end",
+			offset: 13,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			toaster := srv.AddToaster(toasterModel("logs"), nil)
+			srv.SetLogs(toaster.ID, "ex_logs", []byte("first\nsecond\n"))
+
+			out, err := sess.StreamToasterLogs(&toastcloud.StreamToasterLogsInput{ID: toaster.ID, ExeID: "ex_logs", Offset: tt.offset})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []toastcloud.LogLine
+			for line := range out.Stream.Lines() {
+				got = append(got, line)
+			}
+			if err := out.Stream.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %+v, want %+v", got, tt.want)
+			}
+			if n := out.Stream.Offset(); n != 13 {
+				t.Errorf("Offset = %d, want 13", n)
+			}
+		})
+	}
+}
+
+func TestStreamToasterLogsFollow(t *testing.T) {
+	srv, sess := newTestServer(t)
+	toaster := srv.AddToaster(models.Toaster{ExeCmd: []string{"./app"}, JoinableForSec: 60}, nil)
+
+	exe, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLogs(toaster.ID, exe.ExecutionID, []byte("started\n"))
+
+	out, err := sess.StreamToasterLogs(&toastcloud.StreamToasterLogsInput{ID: toaster.ID, ExeID: exe.ExecutionID, Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := out.Stream.Lines()
+
+	if line := <-lines; line.Text != "started\n" {
+		t.Fatalf("first line = %q, want %q", line.Text, "started\n")
+	}
+
+	srv.AppendLogs(toaster.ID, exe.ExecutionID, []byte("done\n"))
+	srv.EndExecution(exe.ExecutionID)
+
+	var got []string
+	for line := range lines {
+		got = append(got, line.Text)
+	}
+	if !reflect.DeepEqual(got, []string{"done\n"}) {
+		t.Errorf("lines after the first = %q, want %q", got, []string{"done\n"})
+	}
+	if err := out.Stream.Err(); err != nil {
+		t.Errorf("Err = %v", err)
+	}
+}
+
+func TestLogStreamClose(t *testing.T) {
+	srv, sess := newTestServer(t)
+	toaster := srv.AddToaster(models.Toaster{ExeCmd: []string{"./app"}, JoinableForSec: 60}, nil)
+
+	exe, err := sess.ExecuteToaster(&toastcloud.ExecuteToasterInput{ID: toaster.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLogs(toaster.ID, exe.ExecutionID, []byte("started\n"))
+
+	out, err := sess.StreamToasterLogs(&toastcloud.StreamToasterLogsInput{ID: toaster.ID, ExeID: exe.ExecutionID, Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(out.Stream)
+		done <- err
+	}()
+
+	if err := out.Stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil && !errors.Is(err, toastcloud.ErrLogStreamClosed) {
+		t.Errorf("Read while closing = %v, want %v", err, toastcloud.ErrLogStreamClosed)
+	}
+
+	if _, err := out.Stream.Read(make([]byte, 1)); !errors.Is(err, toastcloud.ErrLogStreamClosed) {
+		t.Errorf("Read after Close = %v, want %v", err, toastcloud.ErrLogStreamClosed)
+	}
+	if err := out.Stream.Err(); err != nil {
+		t.Errorf("Err after Close = %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package toastcloud
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	DefaultBaseURL    = "https://api.cloud.toastate.com"
+	DefaultAPIVersion = "v1"
+	DefaultUserAgent  = "toastate-sdk-go"
+
+	// DefaultToasterDomain is the domain under which toasters are served, a
+	// toaster being reachable at https://<toaster id>.<domain>.
+	DefaultToasterDomain = "toaster.cloud.toastate.com"
+
+	// Environment variables overriding the defaults of NewSession. Options
+	// passed explicitly to NewSession take precedence over them.
+	EnvAPIURL        = "TOASTATE_API_URL"
+	EnvAPIVersion    = "TOASTATE_API_VERSION"
+	EnvToasterDomain = "TOASTATE_TOASTER_DOMAIN"
+)
+
+type config struct {
+	baseURL    string
+	apiVersion string
+	userAgent  string
+
+	toasterDomain string
+
+	httpClient    *http.Client
+	middlewares   []Middleware
+	timeout       time.Duration
+	uploadTimeout time.Duration
+
+	retry    *RetryPolicy
+	retrySet bool
+	limiter  *RateLimiter
+
+	logger    Logger
+	logBodies bool
+	logCurl   bool
+}
+
+// Option configures a Session created by NewSession.
+type Option func(*config)
+
+func defaultConfig() *config {
+	cfg := &config{
+		baseURL:    DefaultBaseURL,
+		apiVersion: DefaultAPIVersion,
+		userAgent:  DefaultUserAgent,
+
+		toasterDomain: DefaultToasterDomain,
+	}
+
+	if v := os.Getenv(EnvAPIURL); v != "" {
+		cfg.baseURL = v
+	}
+	if v := os.Getenv(EnvAPIVersion); v != "" {
+		cfg.apiVersion = v
+	}
+	if v := os.Getenv(EnvToasterDomain); v != "" {
+		cfg.toasterDomain = v
+	}
+
+	return cfg
+}
+
+// WithBaseURL sets the URL of the API, e.g. "http://localhost:8080" for a
+// local server. When the URL has no scheme, https is used.
+func WithBaseURL(baseURL string) Option {
+	return func(cfg *config) {
+		cfg.baseURL = baseURL
+	}
+}
+
+// WithToasterDomain sets the domain under which toasters are reached by
+// ExecuteToaster. A scheme can be given, e.g. "http://toaster.localhost",
+// https is used otherwise.
+func WithToasterDomain(domain string) Option {
+	return func(cfg *config) {
+		cfg.toasterDomain = domain
+	}
+}
+
+func WithAPIVersion(apiVersion string) Option {
+	return func(cfg *config) {
+		cfg.apiVersion = apiVersion
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(cfg *config) {
+		cfg.userAgent = userAgent
+	}
+}
+
+// WithHTTPClient makes the session send its requests through hc, so that
+// its transport, proxy and TLS settings apply. The timeout of hc is used for
+// regular API calls unless WithTimeout is also given, a client without a
+// timeout keeping the default of 30 seconds.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(cfg *config) {
+		cfg.httpClient = hc
+	}
+}
+
+// WithMiddleware appends middlewares wrapping the transport of every
+// request, uploads and streamed downloads included. The first middleware is
+// the outermost one.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(cfg *config) {
+		cfg.middlewares = append(cfg.middlewares, middlewares...)
+	}
+}
+
+// WithTimeout sets the timeout of regular API calls, 30 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithUploadTimeout sets the timeout of long requests: code uploads made by
+// CreateToaster and UpdateToaster, file downloads and log streams.
+func WithUploadTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.uploadTimeout = timeout
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy. A nil policy disables retries.
+func WithRetryPolicy(p *RetryPolicy) Option {
+	return func(cfg *config) {
+		cfg.retry = p
+		cfg.retrySet = true
+	}
+}
+
+// WithRateLimiter makes the session wait for l before sending each request.
+// Share l between sessions to keep all of them under a single quota.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(cfg *config) {
+		cfg.limiter = l
+	}
+}
+
+// WithLogger logs the method, URL, status and latency of every request to l,
+// at the debug level. Credentials are never logged.
+func WithLogger(l Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = l
+	}
+}
+
+// WithBodyLogging also logs the JSON bodies of requests and responses, with
+// passwords, tokens and git credentials redacted. It requires WithLogger.
+func WithBodyLogging() Option {
+	return func(cfg *config) {
+		cfg.logBodies = true
+	}
+}
+
+// WithCurlLogging also logs every request as an equivalent curl command,
+// with credentials redacted. It requires WithLogger.
+func WithCurlLogging() Option {
+	return func(cfg *config) {
+		cfg.logCurl = true
+	}
+}
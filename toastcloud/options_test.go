@@ -0,0 +1,114 @@
+package toastcloud_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+// requestRecorder records the method and path of the requests going
+// through the transport it wraps.
+type requestRecorder struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (r *requestRecorder) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		r.mu.Lock()
+		r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+		r.mu.Unlock()
+		return next.RoundTrip(req)
+	})
+}
+
+func (r *requestRecorder) sent(prefix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, req := range r.requests {
+		if strings.HasPrefix(req, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options func(srv *toastcloudtest.Server, rec *requestRecorder) []toastcloud.Option
+	}{
+		{
+			name: "middleware",
+			options: func(srv *toastcloudtest.Server, rec *requestRecorder) []toastcloud.Option {
+				return []toastcloud.Option{toastcloud.WithMiddleware(rec.wrap)}
+			},
+		},
+		{
+			name: "http client",
+			options: func(srv *toastcloudtest.Server, rec *requestRecorder) []toastcloud.Option {
+				hc := srv.HTTPClient()
+				hc.Transport = rec.wrap(hc.Transport)
+				return []toastcloud.Option{toastcloud.WithHTTPClient(hc)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := toastcloudtest.NewServer()
+			defer srv.Close()
+
+			rec := &requestRecorder{}
+			sess := srv.Session(tt.options(srv, rec)...)
+
+			// Multipart uploads, of a folder and of files in memory.
+			created, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+				CodeFolder: writeCodeFolder(t, map[string]string{"main.go": "package main"}),
+				ExeCmd:     []string{"./app"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = sess.UpdateToaster(&toastcloud.UpdateToasterInput{
+				ID:        created.Toaster.ID,
+				Codes:     [][]byte{[]byte("package main")},
+				CodePaths: []string{"main.go"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Streamed download.
+			srv.SetLogs(created.Toaster.ID, "ex_logs", []byte("line\n"))
+			out, err := sess.StreamToasterLogs(&toastcloud.StreamToasterLogsInput{ID: created.Toaster.ID, ExeID: "ex_logs"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			logs, err := io.ReadAll(out.Stream)
+			out.Stream.Close()
+			if err != nil || string(logs) != "line\n" {
+				t.Fatalf("logs = %q, %v", logs, err)
+			}
+
+			for _, prefix := range []string{"POST /toaster", "PUT /toaster/" + created.Toaster.ID, "GET /toaster/logs/stream/"} {
+				if rec.sent(prefix) == 0 {
+					t.Errorf("no %s request went through the transport, got %q", prefix, rec.requests)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package toastcloud_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault toastcloudtest.Fault
+		call  func(sess *toastcloud.Session, id string) error
+		check func(err error) bool
+		// requests is the number of requests expected on path, the path of
+		// the toaster when empty.
+		method, path string
+		requests     int
+	}{
+		{
+			name:     "transient error retried",
+			fault:    toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusServiceUnavailable, Times: 2},
+			call:     getToaster,
+			check:    func(err error) bool { return err == nil },
+			method:   "GET",
+			requests: 3,
+		},
+		{
+			name:  "retries exhausted",
+			fault: toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusServiceUnavailable},
+			call:  getToaster,
+			check: func(err error) bool {
+				return errors.Is(err, &toastcloud.APIError{Status: http.StatusServiceUnavailable})
+			},
+			method:   "GET",
+			requests: 3,
+		},
+		{
+			name:     "client error not retried",
+			fault:    toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusBadRequest, Times: 1},
+			call:     getToaster,
+			check:    func(err error) bool { return errors.Is(err, &toastcloud.APIError{Status: http.StatusBadRequest}) },
+			method:   "GET",
+			requests: 1,
+		},
+		{
+			name: "rate limited post retried",
+			fault: toastcloudtest.Fault{
+				Method: "POST",
+				Path:   "/toaster",
+				Status: http.StatusTooManyRequests,
+				Header: http.Header{"Retry-After": []string{"0"}},
+				Times:  1,
+			},
+			call:     createToaster,
+			check:    func(err error) bool { return err == nil },
+			method:   "POST",
+			path:     "/toaster",
+			requests: 2,
+		},
+		{
+			name: "rate limited with a long retry after",
+			fault: toastcloudtest.Fault{
+				Path:   "/toaster/",
+				Status: http.StatusTooManyRequests,
+				Header: http.Header{"Retry-After": []string{"3600"}},
+			},
+			call: getToaster,
+			check: func(err error) bool {
+				var apierr *toastcloud.APIError
+				return errors.As(err, &apierr) && apierr.Status == http.StatusTooManyRequests && apierr.RetryAfter == time.Hour
+			},
+			method:   "GET",
+			requests: 1,
+		},
+		{
+			name: "server error on post not retried",
+			fault: toastcloudtest.Fault{
+				Method: "POST",
+				Path:   "/toaster",
+				Status: http.StatusServiceUnavailable,
+			},
+			call: createToaster,
+			check: func(err error) bool {
+				return errors.Is(err, &toastcloud.APIError{Status: http.StatusServiceUnavailable})
+			},
+			method:   "POST",
+			path:     "/toaster",
+			requests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sess := newTestServer(t)
+			toaster := srv.AddToaster(toasterModel("retries"), nil)
+			srv.InjectFault(tt.fault)
+
+			err := tt.call(sess, toaster.ID)
+			if !tt.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			path := tt.path
+			if path == "" {
+				path = "/toaster/" + toaster.ID
+			}
+			if n := len(srv.RequestsTo(tt.method, path)); n != tt.requests {
+				t.Errorf("%d %s %s requests, want %d", n, tt.method, path, tt.requests)
+			}
+		})
+	}
+}
+
+func createToaster(sess *toastcloud.Session, id string) error {
+	_, err := sess.CreateToaster(&toastcloud.CreateToasterInput{ExeCmd: []string{"true"}})
+	return err
+}
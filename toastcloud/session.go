@@ -1,30 +1,207 @@
 package toastcloud
 
 import (
-	"log"
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/toastate/toastate-sdk-go/internal/apiclient"
+	"github.com/toastate/toastate-sdk-go/toastcloud/credentials"
 )
 
+// RetryPolicy controls how failed requests are retried, see
+// DefaultRetryPolicy for the policy used by new sessions.
+type RetryPolicy = apiclient.RetryPolicy
+
+// Middleware wraps the http.RoundTripper used by a session, e.g. to add
+// headers, metrics or tracing to every request.
+type Middleware = apiclient.Middleware
+
+// Logger receives the debug output of a session, see WithLogger. A
+// *slog.Logger can be used as is.
+type Logger = apiclient.Logger
+
+// RateLimit is the API quota reported by the last response, see
+// Session.RateLimit.
+type RateLimit = apiclient.RateLimit
+
+// ArchiveFormat is the format of toaster code uploaded as a single archive.
+type ArchiveFormat = apiclient.ArchiveFormat
+
+const (
+	ArchiveTarGz = apiclient.ArchiveTarGz
+	ArchiveZip   = apiclient.ArchiveZip
+)
+
+// UploadProgress reports how far an upload of the code of a toaster is.
+type UploadProgress = apiclient.UploadProgress
+
+// RateLimiter is a token bucket limiting the rate of requests of the
+// sessions it is given to, see WithRateLimiter.
+type RateLimiter = apiclient.RateLimiter
+
 type Session struct {
 	client *apiclient.Client
+
+	toasterScheme string
+	toasterDomain string
 }
 
-func NewSession() *Session {
-	return &Session{
-		client: apiclient.NewClient("api.cloud.toastate.com", "v1"),
+// NewSession returns a session configured by the environment variables and
+// opts. An invalid base URL or API version is reported by Err, and returned
+// by every call of the session.
+func NewSession(opts ...Option) *Session {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	client := apiclient.NewClient(cfg.baseURL, cfg.apiVersion).
+		SetUserAgent(cfg.userAgent).
+		Use(cfg.middlewares...)
+	if cfg.httpClient != nil {
+		client = client.SetHTTPClient(cfg.httpClient)
+	}
+	if cfg.timeout > 0 {
+		client = client.SetTimeout(cfg.timeout)
+	}
+	if cfg.uploadTimeout > 0 {
+		client = client.SetUploadTimeout(cfg.uploadTimeout)
+	}
+	if cfg.logger != nil {
+		client = client.SetLogger(cfg.logger, cfg.logBodies, cfg.logCurl)
+	}
+	if cfg.limiter != nil {
+		client = client.SetRateLimiter(cfg.limiter)
+	}
+	if cfg.retrySet {
+		client = client.SetRetryPolicy(cfg.retry)
+	}
+
+	sess := &Session{
+		client:        client,
+		toasterScheme: "https",
+		toasterDomain: cfg.toasterDomain,
+	}
+	if i := strings.Index(cfg.toasterDomain, "://"); i >= 0 {
+		sess.toasterScheme = cfg.toasterDomain[:i]
+		sess.toasterDomain = cfg.toasterDomain[i+3:]
 	}
+	sess.toasterDomain = strings.Trim(sess.toasterDomain, "./")
+
+	return sess
+}
+
+// Err returns the configuration error of the session, e.g. an invalid
+// TOASTATE_API_URL, if any.
+func (sess *Session) Err() error {
+	return sess.client.Err()
+}
+
+// NewSessionFromEnvironment returns a session authenticated with the
+// credentials found in the environment variables or in the shared
+// credentials file, see credentials.NewDefaultChain.
+func NewSessionFromEnvironment(opts ...Option) (*Session, error) {
+	return NewSessionWithCredentials(credentials.NewDefaultChain(), opts...)
 }
 
-func (sess *Session) SetAuth(auth string) *Session {
-	if strings.HasPrefix(auth, "sess_") {
-		sess.client = sess.client.SetAuthToken(auth)
+// NewSessionWithCredentials returns a session authenticated with the
+// credentials retrieved from p. The endpoint set in a credentials profile is
+// used unless it is overridden by an environment variable or an option.
+func NewSessionWithCredentials(p credentials.Provider, opts ...Option) (*Session, error) {
+	v, err := p.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+
+	var profileOpts []Option
+	if env := os.Getenv(EnvAPIURL); env != "" {
+		if _, err := apiclient.ParseBaseURL(env); err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvAPIURL, err)
+		}
+	} else if v.APIURL != "" {
+		if _, err := apiclient.ParseBaseURL(v.APIURL); err != nil {
+			return nil, fmt.Errorf("api_url of the credentials: %w", err)
+		}
+		profileOpts = append(profileOpts, WithBaseURL(v.APIURL))
+	}
+	if v.APIVersion != "" && os.Getenv(EnvAPIVersion) == "" {
+		profileOpts = append(profileOpts, WithAPIVersion(v.APIVersion))
+	}
+
+	sess := NewSession(append(profileOpts, opts...)...)
+	// Options can still override the URL with an invalid one.
+	if err := sess.Err(); err != nil {
+		return nil, err
+	}
+
+	if v.Token != "" {
+		err = sess.SetAuth(v.Token)
 	} else {
-		log.Fatal("invalid authentication")
+		err = sess.SetAPIKey(v.APIKey, v.APISecret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return apiclient.DefaultRetryPolicy()
+}
+
+// NewRateLimiter allows rate requests per second on average, with bursts of
+// up to burst requests. The rate can not go below one request per hour, a
+// lower or negative rate is raised to it.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return apiclient.NewRateLimiter(rate, burst)
+}
+
+// SetAuth authenticates the session with a session token, as returned by
+// Signin.
+func (sess *Session) SetAuth(auth string) error {
+	if !strings.HasPrefix(auth, SessionPrefix) || auth == SessionPrefix {
+		return ErrInvalidCredential
 	}
 
-	// TODO: API Keys
+	sess.client = sess.client.SetAuthToken(auth)
+	return nil
+}
 
+// SetAPIKey authenticates the session with an API key. When secret is not
+// empty, every request is also signed with it.
+func (sess *Session) SetAPIKey(key, secret string) error {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return ErrInvalidCredential
+	}
+
+	sess.client = sess.client.SetAPIKey(key, secret)
+	return nil
+}
+
+// SetCredential picks the authentication scheme from the prefix of
+// credential: session tokens and unsigned API keys are accepted.
+func (sess *Session) SetCredential(credential string) error {
+	switch {
+	case strings.HasPrefix(credential, SessionPrefix):
+		return sess.SetAuth(credential)
+	case strings.HasPrefix(credential, APIKeyPrefix):
+		return sess.SetAPIKey(credential, "")
+	default:
+		return ErrInvalidCredential
+	}
+}
+
+// SetRetryPolicy replaces the retry policy of the session. A nil policy
+// disables retries.
+func (sess *Session) SetRetryPolicy(p *RetryPolicy) *Session {
+	sess.client = sess.client.SetRetryPolicy(p)
 	return sess
 }
+
+// RateLimit returns the API quota as reported by the last response that
+// carried rate limit headers.
+func (sess *Session) RateLimit() RateLimit {
+	return sess.client.RateLimit()
+}
@@ -0,0 +1,209 @@
+package toastcloud_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/credentials"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+// newTestServer returns a fake API and a session to it, retrying without
+// waiting.
+func newTestServer(t *testing.T) (*toastcloudtest.Server, *toastcloud.Session) {
+	t.Helper()
+
+	srv := toastcloudtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	retry := toastcloud.DefaultRetryPolicy()
+	retry.MinBackoff = time.Millisecond
+	retry.MaxBackoff = time.Millisecond
+
+	return srv, srv.Session(toastcloud.WithRetryPolicy(retry))
+}
+
+func toasterModel(name string) models.Toaster {
+	return models.Toaster{Name: name, ExeCmd: []string{"./app"}}
+}
+
+// writeCodeFolder writes files, keyed by their slash separated path, to a
+// temporary folder and returns it.
+func writeCodeFolder(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		valid   bool
+	}{
+		{"https", "https://api.test", true},
+		{"no scheme", "api.test", true},
+		{"ftp", "ftp://api.test", false},
+		{"no host", "https://", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := toastcloud.NewSession(toastcloud.WithBaseURL(tt.baseURL))
+			if (sess.Err() == nil) != tt.valid {
+				t.Fatalf("Err = %v, want valid %v", sess.Err(), tt.valid)
+			}
+			if tt.valid {
+				return
+			}
+
+			_, err := sess.ListToasters(&toastcloud.ListToastersInput{})
+			if err == nil || err.Error() != sess.Err().Error() {
+				t.Errorf("ListToasters = %v, want %v", err, sess.Err())
+			}
+		})
+	}
+}
+
+func TestSetAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret func(srv *toastcloudtest.Server) string
+		ok     bool
+	}{
+		{"unsigned", func(srv *toastcloudtest.Server) string { return "" }, true},
+		{"signed", func(srv *toastcloudtest.Server) string { return srv.APISecret }, true},
+		{"wrong secret", func(srv *toastcloudtest.Server) string { return "secret_wrong" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t)
+			sess := srv.UnauthenticatedSession()
+			if err := sess.SetAPIKey(srv.APIKey, tt.secret(srv)); err != nil {
+				t.Fatal(err)
+			}
+
+			// A JSON body, no body, and a multipart upload.
+			name := "renamed"
+			calls := []func() error{
+				func() error {
+					_, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+						CodeFolder: writeCodeFolder(t, map[string]string{"main.go": "package main"}),
+						ExeCmd:     []string{"./app"},
+					})
+					return err
+				},
+				func() error {
+					_, err := sess.ListToasters(&toastcloud.ListToastersInput{})
+					return err
+				},
+				func() error {
+					toaster := srv.AddToaster(toasterModel("signed"), nil)
+					_, err := sess.UpdateToaster(&toastcloud.UpdateToasterInput{ID: toaster.ID, Name: &name})
+					return err
+				},
+			}
+			for _, call := range calls {
+				err := call()
+				if tt.ok && err != nil {
+					t.Fatal(err)
+				}
+				if !tt.ok && !toastcloud.IsUnauthorized(err) {
+					t.Fatalf("error = %v, want an unauthorized error", err)
+				}
+			}
+		})
+	}
+
+	sess := toastcloud.NewSession()
+	if err := sess.SetAPIKey("sess_abc", ""); !errors.Is(err, toastcloud.ErrInvalidCredential) {
+		t.Errorf("SetAPIKey with a session token = %v, want %v", err, toastcloud.ErrInvalidCredential)
+	}
+}
+
+func TestNewSessionWithCredentials(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	file := filepath.Join(t.TempDir(), "credentials")
+	profiles := "[default]\ntoken = " + srv.Token + "\napi_url = " + srv.URL + "\n" +
+		"[invalid]\ntoken = " + srv.Token + "\napi_url = ftp://api.test\n"
+	if err := os.WriteFile(file, []byte(profiles), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		env     string
+		ok      bool
+	}{
+		{"profile endpoint", "default", "", true},
+		{"invalid profile endpoint", "invalid", "", false},
+		{"invalid environment endpoint", "default", "ftp://api.test", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(toastcloud.EnvAPIURL, tt.env)
+
+			sess, err := toastcloud.NewSessionWithCredentials(
+				credentials.NewFileProvider(file, tt.profile),
+				toastcloud.WithHTTPClient(srv.HTTPClient()),
+			)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("NewSessionWithCredentials succeeded with an invalid endpoint")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := sess.ListToasters(&toastcloud.ListToastersInput{}); err != nil {
+				t.Fatal(err)
+			}
+			srv.AssertRequested(t, "GET", "/toaster/list")
+		})
+	}
+}
+
+func TestSetAuth(t *testing.T) {
+	tests := []struct {
+		token string
+		valid bool
+	}{
+		{"sess_abc", true},
+		{"sess_abc+def/ghi=", true},
+		{"sess_", false},
+		{"key_abc", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		err := toastcloud.NewSession().SetAuth(tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("SetAuth(%q) = %v, want valid %v", tt.token, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, toastcloud.ErrInvalidCredential) {
+			t.Errorf("SetAuth(%q) = %v, want %v", tt.token, err, toastcloud.ErrInvalidCredential)
+		}
+	}
+}
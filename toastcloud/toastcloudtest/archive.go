@@ -0,0 +1,66 @@
+package toastcloudtest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+// extractArchive returns the regular files of an uploaded archive, by path.
+func extractArchive(b []byte, format string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	switch toastcloud.ArchiveFormat(format) {
+	case toastcloud.ArchiveTarGz:
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return files, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			files[h.Name], err = io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	case toastcloud.ArchiveZip:
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			files[f.Name], err = io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return files, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+}
@@ -0,0 +1,138 @@
+package toastcloudtest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+type build struct {
+	id        string
+	toasterID string
+	version   int
+	start     time.Time
+	duration  time.Duration
+	failure   string
+	logs      []byte
+}
+
+func (b *build) model() models.Build {
+	m := models.Build{
+		ID:        b.id,
+		ToasterID: b.toasterID,
+		Version:   b.version,
+		StartTime: b.start,
+	}
+
+	elapsed := time.Since(b.start)
+	if elapsed < b.duration {
+		m.Status = models.BuildStatusRunning
+		m.Stage = "building"
+		m.Progress = int(100 * elapsed / b.duration)
+		return m
+	}
+
+	m.Status = models.BuildStatusSucceeded
+	if b.failure != "" {
+		m.Status = models.BuildStatusFailed
+		m.Error = b.failure
+	}
+	m.Progress = 100
+	m.EndTime = b.start.Add(b.duration)
+	return m
+}
+
+// SetBuildDuration sets how long asynchronous builds take. Builds that are
+// not asynchronous are done right away.
+func (s *Server) SetBuildDuration(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buildDuration = d
+}
+
+// SetBuildFailure makes the next builds of a toaster fail with message,
+// until it is called again with an empty message.
+func (s *Server) SetBuildFailure(id, message string) {
+	s.withToaster(id, func(t *toaster) { t.buildFailure = message })
+}
+
+// startBuild records a build of the current version of t.
+func (s *Server) startBuild(t *toaster, async bool) *build {
+	b := &build{
+		id:        toastcloud.BuildIDPrefix + s.nextID(),
+		toasterID: t.ID,
+		version:   t.Version,
+		start:     time.Now(),
+		failure:   t.buildFailure,
+		logs:      buildLogs(t),
+	}
+	if b.failure != "" {
+		b.logs = append(b.logs, "toastcloudtest: build failed: "+b.failure+"\n"...)
+	}
+	if async {
+		b.duration = s.buildDuration
+	}
+	s.builds[b.id] = b
+	return b
+}
+
+// output returns the logs produced so far: running builds output their logs
+// progressively.
+func (b *build) output() ([]byte, bool) {
+	elapsed := time.Since(b.start)
+	if elapsed >= b.duration {
+		return b.logs, true
+	}
+	return b.logs[:int(int64(len(b.logs))*int64(elapsed)/int64(b.duration))], false
+}
+
+func (s *Server) streamBuildLogs(w http.ResponseWriter, req *Request, userID string) {
+	id, buildID := splitParam(pathParam(req.Path, "/toaster/build/logs/stream/"))
+
+	s.mu.Lock()
+	t := s.ownedToaster(w, id, userID)
+	if t == nil {
+		s.mu.Unlock()
+		return
+	}
+	b, ok := s.builds[buildID]
+	if !ok || b.toasterID != t.ID {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "build_not_found", "build "+buildID+" not found")
+		return
+	}
+	s.mu.Unlock()
+
+	s.streamLogs(w, req, b.output)
+}
+
+func (s *Server) getBuild(w http.ResponseWriter, req *Request, userID string) {
+	id, buildID := splitParam(pathParam(req.Path, "/toaster/build/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, id, userID)
+	if t == nil {
+		return
+	}
+
+	b, ok := s.builds[buildID]
+	if !ok || b.toasterID != t.ID {
+		writeError(w, http.StatusNotFound, "build_not_found", "build "+buildID+" not found")
+		return
+	}
+
+	m := b.model()
+	resp := map[string]interface{}{
+		"success": true,
+		"build":   m,
+	}
+	if !m.EndTime.IsZero() {
+		resp["build_logs"] = b.logs
+	}
+	writeJSON(w, resp)
+}
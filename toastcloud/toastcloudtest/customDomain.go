@@ -0,0 +1,150 @@
+package toastcloudtest
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+)
+
+const CNAMETarget = "cname.toastate.test"
+
+type customDomainRequest struct {
+	RootDomain    string            `json:"root_domain"`
+	Domains       []string          `json:"domains"`
+	LinkedToaster map[string]string `json:"linked_toasters"`
+}
+
+func (s *Server) ownedCustomDomain(w http.ResponseWriter, id, userID string) *models.CustomDomain {
+	cd, ok := s.customDomains[id]
+	if !ok || cd.UserID != userID {
+		writeError(w, http.StatusNotFound, "custom_domain_not_found", "custom domain "+id+" not found")
+		return nil
+	}
+	return cd
+}
+
+func (s *Server) writeCustomDomain(w http.ResponseWriter, cd *models.CustomDomain) {
+	cnames := map[string]string{}
+	for _, d := range cd.Domains {
+		cnames[d] = CNAMETarget
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":                          true,
+		"custom_domain":                    cd,
+		"ownership_check_txt_record_name":  "_toastate." + cd.RootDomain,
+		"ownership_check_txt_record_value": cd.VerificationToken,
+		"cnames_record":                    cnames,
+	})
+}
+
+func (s *Server) listCustomDomains(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cds := []models.CustomDomain{}
+	for _, cd := range s.customDomains {
+		if cd.UserID == userID {
+			cds = append(cds, *cd)
+		}
+	}
+	sort.Slice(cds, func(i, j int) bool { return cds[i].ID < cds[j].ID })
+
+	writeJSON(w, map[string]interface{}{
+		"success":        true,
+		"custom_domains": cds,
+	})
+}
+
+func (s *Server) createCustomDomain(w http.ResponseWriter, req *Request, userID string) {
+	in := &customDomainRequest{}
+	if err := req.Decode(in); err != nil || in.RootDomain == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "root_domain is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cd := &models.CustomDomain{
+		ID:                s.nextID(),
+		RootDomain:        in.RootDomain,
+		Domains:           in.Domains,
+		UserID:            userID,
+		LinkedToaster:     in.LinkedToaster,
+		VerificationToken: "toastate-verification=" + s.nextID(),
+	}
+	s.customDomains[cd.ID] = cd
+
+	s.writeCustomDomain(w, cd)
+}
+
+// verifyCustomDomain always succeeds: the fake does not resolve DNS.
+func (s *Server) verifyCustomDomain(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cd := s.ownedCustomDomain(w, pathParam(req.Path, "/customdomain/verify/"), userID)
+	if cd == nil {
+		return
+	}
+	cd.Enabled = true
+	cd.SSL = true
+
+	writeJSON(w, map[string]interface{}{
+		"success":       true,
+		"custom_domain": cd,
+	})
+}
+
+func (s *Server) getCustomDomain(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cd := s.ownedCustomDomain(w, pathParam(req.Path, "/customdomain/"), userID)
+	if cd == nil {
+		return
+	}
+
+	s.writeCustomDomain(w, cd)
+}
+
+func (s *Server) updateCustomDomain(w http.ResponseWriter, req *Request, userID string) {
+	in := &customDomainRequest{}
+	if err := req.Decode(in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cd := s.ownedCustomDomain(w, pathParam(req.Path, "/customdomain/"), userID)
+	if cd == nil {
+		return
+	}
+	if in.Domains != nil {
+		cd.Domains = in.Domains
+	}
+	if in.LinkedToaster != nil {
+		cd.LinkedToaster = in.LinkedToaster
+	}
+
+	s.writeCustomDomain(w, cd)
+}
+
+func (s *Server) deleteCustomDomain(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cd := s.ownedCustomDomain(w, pathParam(req.Path, "/customdomain/"), userID)
+	if cd == nil {
+		return
+	}
+	delete(s.customDomains, cd.ID)
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,389 @@
+package toastcloudtest
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+type execution struct {
+	id        string
+	toasterID string
+	forced    bool
+	start     time.Time
+	end       time.Time
+
+	// joiners is the number of requests being served, the first one
+	// included.
+	joiners int
+	// joined is the number of requests that joined the execution so far.
+	joined int
+
+	// status and exitCode are set when the execution ends.
+	status   string
+	exitCode int
+}
+
+func (exe *execution) model() models.Execution {
+	status := exe.status
+	if exe.end.IsZero() {
+		status = models.ExecutionStatusRunning
+	}
+	return models.Execution{
+		ID:          exe.id,
+		ToasterID:   exe.toasterID,
+		Forced:      exe.forced,
+		Status:      status,
+		ExitCode:    exe.exitCode,
+		StartTime:   exe.start,
+		EndTime:     exe.end,
+		JoinerCount: exe.joined,
+	}
+}
+
+// EndExecution marks an execution as succeeded: it can not be joined
+// anymore.
+func (s *Server) EndExecution(exeID string) {
+	s.EndExecutionWithStatus(exeID, models.ExecutionStatusSucceeded, 0)
+}
+
+// EndExecutionWithStatus marks an execution as ended with the given
+// models.ExecutionStatus* value and exit code.
+func (s *Server) EndExecutionWithStatus(exeID, status string, exitCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if exe, ok := s.executions[exeID]; ok && exe.end.IsZero() {
+		exe.end = time.Now()
+		exe.status = status
+		exe.exitCode = exitCode
+	}
+}
+
+// SetToasterHandler makes h serve the requests sent to a toaster, in place
+// of the default handler which echoes the request back as JSON.
+func (s *Server) SetToasterHandler(id string, h http.Handler) {
+	s.withToaster(id, func(t *toaster) { t.handler = h })
+}
+
+// toasterForHost returns the ID of the toaster served at host, which is
+// either a toaster domain or a custom domain linked to a toaster.
+func (s *Server) toasterForHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if strings.HasSuffix(host, DomainSuffix) {
+		return strings.TrimSuffix(host, DomainSuffix)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cd := range s.customDomains {
+		if id, ok := cd.LinkedToaster[host]; ok && cd.Enabled {
+			return id
+		}
+	}
+	return ""
+}
+
+func (s *Server) execute(w http.ResponseWriter, r *http.Request, req *Request, toasterID string) {
+	s.mu.Lock()
+	t, ok := s.toasters[toasterID]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "toaster_not_found", "toaster "+toasterID+" not found")
+		return
+	}
+
+	var exe *execution
+	if joinID := r.Header.Get(toastcloud.ExecutionIDHeader); joinID != "" {
+		var status, code string
+		var httpStatus int
+		exe, status, httpStatus, code = s.join(t, joinID)
+		w.Header().Set(toastcloud.JoinStatusHeader, status)
+		if exe == nil {
+			s.mu.Unlock()
+			writeError(w, httpStatus, code, "can not join execution "+joinID)
+			return
+		}
+	} else {
+		exe = &execution{
+			id:        toastcloud.ExecutionIDPrefix + s.nextID(),
+			toasterID: toasterID,
+			start:     time.Now(),
+			joiners:   1,
+		}
+		if r.Header.Get(toastcloud.ForceNewExecutionHeader) == "true" {
+			exe.id = toastcloud.ExecutionForcedExeIDPrefix + s.nextID()
+			exe.forced = true
+		}
+		s.executions[exe.id] = exe
+	}
+
+	t.logs[exe.id] = append(t.logs[exe.id], []byte("toastcloudtest: "+r.Method+" "+r.URL.Path+"\n")...)
+	handler := t.handler
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		exe.joiners--
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set(toastcloud.ExecutionIDHeader, exe.id)
+
+	if handler != nil {
+		r.Body = io.NopCloser(bytes.NewReader(req.Body))
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"toaster_id":   toasterID,
+		"execution_id": exe.id,
+		"method":       r.Method,
+		"path":         r.URL.Path,
+		"body":         string(req.Body),
+	})
+}
+
+// join adds a joiner to an execution, following the JoinableForSec and
+// MaxConcurrentJoiners settings of t. On failure, it returns the join status
+// with the HTTP status and error code to answer with.
+func (s *Server) join(t *toaster, exeID string) (*execution, string, int, string) {
+	exe, ok := s.executions[exeID]
+	if !ok || exe.toasterID != t.ID || exe.forced || !exe.end.IsZero() {
+		return nil, "not_found", http.StatusNotFound, "execution_not_found"
+	}
+	if t.draining {
+		return nil, "draining", http.StatusServiceUnavailable, "toaster_draining"
+	}
+	if time.Since(exe.start) > time.Duration(t.JoinableForSec)*time.Second {
+		return nil, "expired", http.StatusGone, "join_window_expired"
+	}
+	if t.MaxConcurrentJoiners > 0 && exe.joiners >= t.MaxConcurrentJoiners {
+		return nil, "full", http.StatusConflict, "joiner_limit_reached"
+	}
+
+	exe.joiners++
+	exe.joined++
+	return exe, "joined", 0, ""
+}
+
+// streamToasterLogs writes the logs of an execution from the requested
+// offset. When following, it polls for new logs until the execution ends.
+func (s *Server) streamToasterLogs(w http.ResponseWriter, req *Request, userID string) {
+	id, exeID := splitParam(pathParam(req.Path, "/toaster/logs/stream/"))
+
+	s.mu.Lock()
+	if s.ownedToaster(w, id, userID) == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	s.streamLogs(w, req, func() ([]byte, bool) {
+		var logs []byte
+		if t, ok := s.toasters[id]; ok {
+			logs = t.logs[exeID]
+		}
+		ended := true
+		if exe, ok := s.executions[exeID]; ok {
+			ended = !exe.end.IsZero()
+		}
+		return logs, ended
+	})
+}
+
+// streamLogs writes the logs returned by current from the requested offset.
+// When following, it polls current, called with s.mu held, until it reports
+// that the logs ended.
+func (s *Server) streamLogs(w http.ResponseWriter, req *Request, current func() (logs []byte, ended bool)) {
+	offset, _ := strconv.Atoi(req.Query.Get("offset"))
+	follow := req.Query.Get("follow") == "true"
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		s.mu.Lock()
+		logs, ended := current()
+		s.mu.Unlock()
+
+		if offset < len(logs) {
+			n, err := w.Write(logs[offset:])
+			offset += n
+			if err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if !follow || ended {
+			return
+		}
+
+		select {
+		case <-time.After(20 * time.Millisecond):
+		case <-req.ctx.Done():
+			return
+		}
+	}
+}
+
+// listExecutions lists the executions of a toaster, most recent first, with
+// the filters of ListExecutions. Its next_token is the offset of the page.
+func (s *Server) listExecutions(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/executions/"), userID)
+	if t == nil {
+		return
+	}
+
+	var from, to time.Time
+	var err error
+	if v := req.Query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_from", err.Error())
+			return
+		}
+	}
+	if v := req.Query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_to", err.Error())
+			return
+		}
+	}
+	status := req.Query.Get("status")
+	forced := req.Query.Get("forced")
+
+	exes := []models.Execution{}
+	for _, exe := range s.executions {
+		m := exe.model()
+		switch {
+		case m.ToasterID != t.ID,
+			!from.IsZero() && m.StartTime.Before(from),
+			!to.IsZero() && !m.StartTime.Before(to),
+			status != "" && m.Status != status,
+			forced != "" && strconv.FormatBool(m.Forced) != forced:
+			continue
+		}
+		exes = append(exes, m)
+	}
+	sort.Slice(exes, func(i, j int) bool {
+		if exes[i].StartTime.Equal(exes[j].StartTime) {
+			return exes[i].ID > exes[j].ID
+		}
+		return exes[i].StartTime.After(exes[j].StartTime)
+	})
+
+	offset, _ := strconv.Atoi(req.Query.Get("next_token"))
+	if offset > len(exes) {
+		offset = len(exes)
+	}
+	exes = exes[offset:]
+
+	nextToken := ""
+	if limit, _ := strconv.Atoi(req.Query.Get("limit")); limit > 0 && limit < len(exes) {
+		exes = exes[:limit]
+		nextToken = strconv.Itoa(offset + limit)
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":    true,
+		"executions": exes,
+		"next_token": nextToken,
+	})
+}
+
+func (s *Server) getExecution(w http.ResponseWriter, req *Request, userID string) {
+	id, exeID := splitParam(pathParam(req.Path, "/toaster/execution/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, id, userID)
+	if t == nil {
+		return
+	}
+
+	exe, ok := s.executions[exeID]
+	if !ok || exe.toasterID != t.ID {
+		writeError(w, http.StatusNotFound, "execution_not_found", "execution "+exeID+" not found")
+		return
+	}
+
+	m := exe.model()
+	stats := t.stats
+	m.Stats = &stats
+
+	writeJSON(w, map[string]interface{}{
+		"success":   true,
+		"execution": m,
+	})
+}
+
+// endExecution answers StopExecution and KillExecution. Executions of the
+// fake server end as soon as they are asked to: stopped ones exit with
+// SIGTERM's exit code and killed ones with SIGKILL's.
+func (s *Server) endExecution(w http.ResponseWriter, req *Request, userID string) {
+	verb, param := splitParam(pathParam(req.Path, "/toaster/execution/"))
+	id, exeID := splitParam(param)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, id, userID)
+	if t == nil {
+		return
+	}
+
+	exe, ok := s.executions[exeID]
+	if !ok || exe.toasterID != t.ID {
+		writeError(w, http.StatusNotFound, "execution_not_found", "execution "+exeID+" not found")
+		return
+	}
+
+	if exe.end.IsZero() {
+		exe.end = time.Now()
+		exe.status, exe.exitCode = models.ExecutionStatusStopped, 143
+		if verb == "kill" {
+			exe.status, exe.exitCode = models.ExecutionStatusKilled, 137
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":   true,
+		"execution": exe.model(),
+	})
+}
+
+func (s *Server) drainToaster(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/drain/"), userID)
+	if t == nil {
+		return
+	}
+	t.draining = true
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,524 @@
+// Package toastcloudtest provides an in-process fake of the Toastate API, to
+// test code built on toastcloud.Session without reaching the real cloud.
+//
+//	srv := toastcloudtest.NewServer()
+//	defer srv.Close()
+//
+//	sess := srv.Session()
+//	out, err := sess.CreateToaster(&toastcloud.CreateToasterInput{...})
+//	srv.AssertRequested(t, "POST", "/toaster")
+package toastcloudtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+const (
+	DefaultEmail    = "test@toastate.test"
+	DefaultPassword = "password"
+)
+
+// Server is a fake Toastate API keeping its state in memory. It is safe for
+// concurrent use.
+type Server struct {
+	*httptest.Server
+
+	// Token and APIKey authenticate as User, which is created by NewServer.
+	// Requests made with APIKey can be signed with APISecret, those carrying
+	// an invalid signature are rejected.
+	User      models.User
+	Token     string
+	APIKey    string
+	APISecret string
+
+	mu            sync.Mutex
+	seq           int
+	users         map[string]*user
+	tokens        map[string]string
+	toasters      map[string]*toaster
+	customDomains map[string]*models.CustomDomain
+	executions    map[string]*execution
+	builds        map[string]*build
+	buildDuration time.Duration
+	faults        []*Fault
+	requests      []Request
+}
+
+type user struct {
+	models.User
+	password string
+}
+
+// Fault makes the server answer matching requests with an error.
+type Fault struct {
+	// Method matches any method when empty.
+	Method string
+	// Path is a prefix of the request path, e.g. "/toaster/".
+	Path string
+
+	Status  int
+	Code    string
+	Message string
+
+	// Header is added to the error response, e.g. a Retry-After header.
+	Header http.Header
+
+	// Delay is waited before answering.
+	Delay time.Duration
+
+	// Times is the number of requests the fault applies to, 0 means all of
+	// them.
+	Times int
+}
+
+// Request is a request received by the server.
+type Request struct {
+	// Host is set for requests sent to a toaster.
+	Host string
+
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+
+	// Body is the JSON body of the request, or the "request" field of
+	// multipart uploads.
+	Body []byte
+
+	// Files holds the files of multipart uploads, by path.
+	Files map[string][]byte
+
+	// Archive is the code of uploads sent as a single archive.
+	Archive []byte
+
+	ctx context.Context
+	// sha256 is the hex encoded hash of the body as received, empty for
+	// multipart uploads.
+	sha256 string
+}
+
+// Decode unmarshals the JSON body of the request into v.
+func (r *Request) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+func NewServer() *Server {
+	s := &Server{
+		users:         map[string]*user{},
+		tokens:        map[string]string{},
+		toasters:      map[string]*toaster{},
+		customDomains: map[string]*models.CustomDomain{},
+		executions:    map[string]*execution{},
+		builds:        map[string]*build{},
+	}
+
+	u := s.addUser(DefaultEmail, DefaultPassword)
+	s.User = u.User
+	s.Token = s.newToken(u.ID)
+	s.APIKey = toastcloud.APIKeyPrefix + s.nextID()
+	s.APISecret = "secret_" + s.nextID()
+	s.tokens[s.APIKey] = u.ID
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Session returns a session authenticated as s.User and sending its requests
+// to s.
+func (s *Server) Session(opts ...toastcloud.Option) *toastcloud.Session {
+	sess := s.UnauthenticatedSession(opts...)
+	if err := sess.SetAuth(s.Token); err != nil {
+		panic(err)
+	}
+	return sess
+}
+
+func (s *Server) UnauthenticatedSession(opts ...toastcloud.Option) *toastcloud.Session {
+	return toastcloud.NewSession(append([]toastcloud.Option{
+		toastcloud.WithBaseURL(s.URL),
+		toastcloud.WithToasterDomain("http://" + ToasterDomain),
+		toastcloud.WithHTTPClient(s.HTTPClient()),
+	}, opts...)...)
+}
+
+// HTTPClient returns a client connecting to s whatever the host of the
+// request, so that toaster domains reach it too.
+func (s *Server) HTTPClient() *http.Client {
+	transport := s.Client().Transport.(*http.Transport).Clone()
+	addr := s.Listener.Addr().String()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsTo returns the requests received for method and path.
+func (s *Server) RequestsTo(method, path string) []Request {
+	var out []Request
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == path {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// AssertRequested fails t when no request was received for method and path,
+// and returns the last one otherwise.
+func (s *Server) AssertRequested(t testing.TB, method, path string) Request {
+	t.Helper()
+
+	reqs := s.RequestsTo(method, path)
+	if len(reqs) == 0 {
+		t.Fatalf("toastcloudtest: expected a %v %v request", method, path)
+		return Request{}
+	}
+	return reqs[len(reqs)-1]
+}
+
+func (s *Server) AssertNotRequested(t testing.TB, method, path string) {
+	t.Helper()
+
+	if n := len(s.RequestsTo(method, path)); n > 0 {
+		t.Fatalf("toastcloudtest: expected no %v %v request, got %d", method, path, n)
+	}
+}
+
+func (s *Server) nextID() string {
+	s.seq++
+	return fmt.Sprintf("%08x", s.seq)
+}
+
+func (s *Server) newToken(userID string) string {
+	token := toastcloud.SessionPrefix + s.nextID()
+	s.tokens[token] = userID
+	return token
+}
+
+func (s *Server) addUser(email, password string) *user {
+	u := &user{
+		User: models.User{
+			ID:    s.nextID(),
+			Email: email,
+		},
+		password: password,
+	}
+	s.users[email] = u
+	return u
+}
+
+// handlerFunc serves a request once its body has been read. userID is empty
+// for unauthenticated requests.
+type handlerFunc func(w http.ResponseWriter, req *Request, userID string)
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	toasterID := s.toasterForHost(r.Host)
+
+	// Requests sent to toasters are kept as is.
+	req, err := readRequest(r, toasterID != "")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if toasterID != "" {
+		req.Host = r.Host
+	}
+
+	s.mu.Lock()
+	s.seq++
+	w.Header().Set("X-Request-Id", fmt.Sprintf("req_%08x", s.seq))
+	s.requests = append(s.requests, *req)
+	fault := s.matchFault(r.Method, r.URL.Path)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.Status != 0 {
+			for k, v := range fault.Header {
+				w.Header()[k] = v
+			}
+			writeError(w, fault.Status, fault.Code, fault.Message)
+			return
+		}
+	}
+
+	if toasterID != "" {
+		s.execute(w, r, req, toasterID)
+		return
+	}
+
+	h, authed := s.route(r.Method, req.Path)
+	if h == nil {
+		writeError(w, http.StatusNotFound, "not_found", "no such endpoint")
+		return
+	}
+
+	var userID string
+	if authed {
+		s.mu.Lock()
+		userID = s.authenticate(r, req)
+		s.mu.Unlock()
+		if userID == "" {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid authentication")
+			return
+		}
+	}
+
+	h(w, req, userID)
+}
+
+func (s *Server) matchFault(method, path string) *Fault {
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+		if !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// authenticate returns the ID of the user r is authenticated as, or an empty
+// string.
+func (s *Server) authenticate(r *http.Request, req *Request) string {
+	if key := r.Header.Get("X-TOASTATE-APIKEY"); key != "" {
+		if r.Header.Get("X-TOASTATE-SIGNATURE") != "" && !s.validSignature(r, req) {
+			return ""
+		}
+		return s.tokens[key]
+	}
+	return s.tokens[r.Header.Get("X-TOASTATE-AUTH")]
+}
+
+// validSignature checks the signature of a request made with APIKey: the
+// HMAC-SHA256, keyed with APISecret, of the newline separated method,
+// request URI, timestamp and content hash. Only multipart uploads can leave
+// their content unsigned.
+func (s *Server) validSignature(r *http.Request, req *Request) bool {
+	timestamp := r.Header.Get("X-TOASTATE-TIMESTAMP")
+	contentHash := r.Header.Get("X-TOASTATE-CONTENT-SHA256")
+	if timestamp == "" {
+		return false
+	}
+	if contentHash != req.sha256 && (req.sha256 != "" || contentHash != "UNSIGNED-PAYLOAD") {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.APISecret))
+	mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp + "\n" + contentHash))
+	want := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(r.Header.Get("X-TOASTATE-SIGNATURE")), []byte(want))
+}
+
+func (s *Server) route(method, path string) (h handlerFunc, authed bool) {
+	switch {
+	case method == "POST" && path == "/signup":
+		return s.signup, false
+	case method == "POST" && path == "/signin":
+		return s.signin, false
+	case method == "POST" && path == "/user/setupbilling":
+		return s.setupBilling, true
+
+	case method == "GET" && path == "/toaster/list":
+		return s.listToasters, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/count/"):
+		return s.toasterCount, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/stats/"):
+		return s.toasterStats, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/listfiles/"):
+		return s.listToasterFiles, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/file/"):
+		return s.getToasterFile, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/build/logs/stream/"):
+		return s.streamBuildLogs, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/build/"):
+		return s.getBuild, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/executions/"):
+		return s.listExecutions, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/execution/"):
+		return s.getExecution, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/logs/stream/"):
+		return s.streamToasterLogs, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/logs/"):
+		return s.getToasterLogs, true
+	case method == "POST" && (strings.HasPrefix(path, "/toaster/execution/stop/") || strings.HasPrefix(path, "/toaster/execution/kill/")):
+		return s.endExecution, true
+	case method == "POST" && strings.HasPrefix(path, "/toaster/drain/"):
+		return s.drainToaster, true
+	case method == "POST" && path == "/toaster":
+		return s.createToaster, true
+	case method == "DELETE" && path == "/toaster":
+		return s.deleteToasters, true
+	case method == "GET" && strings.HasPrefix(path, "/toaster/"):
+		return s.getToaster, true
+	case method == "PUT" && strings.HasPrefix(path, "/toaster/"):
+		return s.updateToaster, true
+
+	case method == "GET" && path == "/customdomain/list":
+		return s.listCustomDomains, true
+	case method == "POST" && path == "/customdomain":
+		return s.createCustomDomain, true
+	case method == "POST" && strings.HasPrefix(path, "/customdomain/verify/"):
+		return s.verifyCustomDomain, true
+	case method == "GET" && strings.HasPrefix(path, "/customdomain/"):
+		return s.getCustomDomain, true
+	case method == "PUT" && strings.HasPrefix(path, "/customdomain/"):
+		return s.updateCustomDomain, true
+	case method == "DELETE" && strings.HasPrefix(path, "/customdomain/"):
+		return s.deleteCustomDomain, true
+	}
+
+	return nil, false
+}
+
+func readRequest(r *http.Request, raw bool) (*Request, error) {
+	req := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		ctx:    r.Context(),
+	}
+
+	if raw || !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		req.sha256 = hex.EncodeToString(sum[:])
+		if !raw {
+			b = bytes.TrimSpace(b)
+		}
+		req.Body = b
+		return req, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Files = map[string][]byte{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		switch part.FormName() {
+		case "request":
+			req.Body = b
+		case "archive":
+			req.Archive = b
+		case "file":
+			name, err := base32.StdEncoding.DecodeString(part.FileName())
+			if err != nil {
+				return nil, fmt.Errorf("invalid file name %q: %v", part.FileName(), err)
+			}
+			req.Files[string(name)] = b
+		}
+	}
+
+	return req, nil
+}
+
+// pathParam returns what follows prefix in path.
+func pathParam(path, prefix string) string {
+	return strings.TrimPrefix(path, prefix)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	if code == "" {
+		code = http.StatusText(status)
+	}
+	if message == "" {
+		message = "fault injected by toastcloudtest"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":    code,
+		"message": message,
+	})
+}
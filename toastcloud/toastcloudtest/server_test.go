@@ -0,0 +1,226 @@
+package toastcloudtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+// recordingTB records the failures of the assertions instead of failing the
+// test.
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (t *recordingTB) Helper() {}
+
+func (t *recordingTB) Fatalf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+// statuses sends n unauthenticated requests and returns their statuses,
+// 401 when no fault applies.
+func statuses(t *testing.T, srv *toastcloudtest.Server, method, path string, n int) []int {
+	t.Helper()
+
+	var out []int
+	for i := 0; i < n; i++ {
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := srv.HTTPClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		out = append(out, response.StatusCode)
+	}
+	return out
+}
+
+func TestInjectFault(t *testing.T) {
+	tests := []struct {
+		name         string
+		fault        toastcloudtest.Fault
+		method, path string
+		want         []int
+	}{
+		{
+			name:   "every request",
+			fault:  toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusInternalServerError},
+			method: "GET",
+			path:   "/toaster/t_missing",
+			want:   []int{500, 500, 500},
+		},
+		{
+			name:   "limited times",
+			fault:  toastcloudtest.Fault{Path: "/toaster/", Status: http.StatusServiceUnavailable, Times: 2},
+			method: "GET",
+			path:   "/toaster/t_missing",
+			want:   []int{503, 503, 401},
+		},
+		{
+			name:   "other method",
+			fault:  toastcloudtest.Fault{Method: "DELETE", Path: "/toaster/", Status: http.StatusInternalServerError},
+			method: "GET",
+			path:   "/toaster/t_missing",
+			want:   []int{401, 401, 401},
+		},
+		{
+			name:   "other path",
+			fault:  toastcloudtest.Fault{Path: "/user/", Status: http.StatusInternalServerError},
+			method: "GET",
+			path:   "/toaster/t_missing",
+			want:   []int{401, 401, 401},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := toastcloudtest.NewServer()
+			defer srv.Close()
+
+			srv.InjectFault(tt.fault)
+			if got := statuses(t, srv, tt.method, tt.path, len(tt.want)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+
+			srv.ClearFaults()
+			if got := statuses(t, srv, tt.method, tt.path, 1); got[0] == tt.fault.Status {
+				t.Errorf("status after ClearFaults = %d", got[0])
+			}
+		})
+	}
+}
+
+func TestAssertRequested(t *testing.T) {
+	srv := toastcloudtest.NewServer()
+	defer srv.Close()
+
+	statuses(t, srv, "GET", "/toaster/t_one", 1)
+	statuses(t, srv, "GET", "/toaster/t_two", 1)
+
+	rec := &recordingTB{TB: t}
+	if req := srv.AssertRequested(rec, "GET", "/toaster/t_one"); req.Path != "/toaster/t_one" {
+		t.Errorf("AssertRequested returned a request to %s", req.Path)
+	}
+	srv.AssertNotRequested(rec, "DELETE", "/toaster/t_one")
+	if len(rec.failures) != 0 {
+		t.Errorf("unexpected failures: %q", rec.failures)
+	}
+
+	srv.AssertRequested(rec, "GET", "/toaster/t_three")
+	srv.AssertNotRequested(rec, "GET", "/toaster/t_two")
+	if len(rec.failures) != 2 {
+		t.Errorf("%d failures, want 2: %q", len(rec.failures), rec.failures)
+	}
+
+	srv.ResetRequests()
+	if reqs := srv.Requests(); len(reqs) != 0 {
+		t.Errorf("%d requests after ResetRequests", len(reqs))
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	srv := toastcloudtest.NewServer()
+	defer srv.Close()
+
+	srv.InjectFault(toastcloudtest.Fault{Path: "/toaster/", Delay: 50 * time.Millisecond, Times: 1})
+
+	start := time.Now()
+	got := statuses(t, srv, "GET", "/toaster/t_missing", 1)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("answered after %v, want a delay of 50ms", elapsed)
+	}
+	// A fault without a status only delays the request.
+	if got[0] != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", got[0], http.StatusUnauthorized)
+	}
+}
+
+func TestToasterCRUD(t *testing.T) {
+	srv := toastcloudtest.NewServer()
+	defer srv.Close()
+	sess := srv.Session()
+
+	created, err := sess.CreateToaster(&toastcloud.CreateToasterInput{
+		Codes:     [][]byte{[]byte("package main")},
+		CodePaths: []string{"main.go"},
+		BuildCmd:  []string{"go", "build", "-o", "app"},
+		ExeCmd:    []string{"./app"},
+		Name:      "hello",
+		Keywords:  []string{"demo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Toaster.ID
+	if id == "" || created.Toaster.Name != "hello" || created.Domain == "" {
+		t.Fatalf("CreateToaster = %+v", created)
+	}
+	if created.Build == nil || created.Build.Status != "succeeded" {
+		t.Errorf("CreateToaster build = %+v, want a succeeded build", created.Build)
+	}
+
+	req := srv.AssertRequested(t, "POST", "/toaster")
+	var body struct {
+		Name      string   `json:"name"`
+		CodePaths []string `json:"code_paths"`
+	}
+	if err := req.Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Name != "hello" || len(body.CodePaths) != 1 || body.CodePaths[0] != "main.go" {
+		t.Errorf("POST /toaster body = %s", req.Body)
+	}
+	if got := string(srv.Files(id)["main.go"]); got != "package main" {
+		t.Errorf("main.go = %q, want %q", got, "package main")
+	}
+
+	got, err := sess.GetToaster(&toastcloud.GetToasterInput{ID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Toaster.ID != id || got.Toaster.Name != "hello" {
+		t.Errorf("GetToaster = %+v", got.Toaster)
+	}
+	srv.AssertRequested(t, "GET", "/toaster/"+id)
+
+	list, err := sess.ListToasters(&toastcloud.ListToastersInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Toasters) != 1 || list.Toasters[0].ID != id {
+		t.Errorf("ListToasters = %+v", list.Toasters)
+	}
+
+	name := "renamed"
+	updated, err := sess.UpdateToaster(&toastcloud.UpdateToasterInput{ID: id, Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Toaster.Name != name {
+		t.Errorf("UpdateToaster name = %q, want %q", updated.Toaster.Name, name)
+	}
+	srv.AssertRequested(t, "PUT", "/toaster/"+id)
+
+	_, err = sess.DeleteToaster(&toastcloud.DeleteToasterInput{IDs: []string{id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Toaster(id); ok {
+		t.Errorf("toaster %s still exists after DeleteToaster", id)
+	}
+
+	_, err = sess.GetToaster(&toastcloud.GetToasterInput{ID: id})
+	if !toastcloud.IsNotFound(err) {
+		t.Errorf("GetToaster of a deleted toaster = %v, want a not found error", err)
+	}
+}
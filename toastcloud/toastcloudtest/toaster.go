@@ -0,0 +1,481 @@
+package toastcloudtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+)
+
+const (
+	// ToasterDomain is the domain toasters are served under, a toaster being
+	// reachable at http://<toaster id>.<ToasterDomain>.
+	ToasterDomain = "toaster.toastate.test"
+
+	// DomainSuffix is appended to toaster IDs to build their domain.
+	DomainSuffix = "." + ToasterDomain
+)
+
+type toaster struct {
+	models.Toaster
+	files   map[string][]byte
+	logs    map[string][]byte
+	running int
+	stats   models.ToasterStats
+	handler http.Handler
+
+	// draining toasters accept no new joiners.
+	draining bool
+
+	// buildFailure is the error of the next builds when not empty.
+	buildFailure string
+}
+
+type codeRequest struct {
+	Codes     [][]byte `json:"codes"`
+	CodePaths []string `json:"code_paths"`
+	GitURL    string   `json:"git_url"`
+
+	ArchiveFormat string `json:"archive_format"`
+
+	Async bool `json:"async"`
+}
+
+type updateToasterRequest struct {
+	codeRequest
+
+	BuildCmd []string `json:"build_command"`
+	ExeCmd   []string `json:"execution_command"`
+	Env      []string `json:"environment_variables"`
+
+	JoinableForSec       *int `json:"joinable_for_seconds"`
+	MaxConcurrentJoiners *int `json:"max_concurrent_joiners"`
+	TimeoutSec           *int `json:"timeout_seconds"`
+
+	Name     *string  `json:"name"`
+	Readme   *string  `json:"readme"`
+	Keywords []string `json:"keywords"`
+
+	Delta        bool     `json:"delta"`
+	DeletedFiles []string `json:"deleted_files"`
+}
+
+// AddToaster stores t with the given code files. t is owned by s.User and
+// gets a new ID unless set.
+func (s *Server) AddToaster(t models.Toaster, files map[string][]byte) models.Toaster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = toastcloud.ToasterIDPrefix + s.nextID()
+	}
+	if t.OwnerID == "" {
+		t.OwnerID = s.User.ID
+	}
+	if files == nil {
+		files = map[string][]byte{}
+	}
+
+	s.toasters[t.ID] = &toaster{
+		Toaster: t,
+		files:   files,
+		logs:    map[string][]byte{},
+	}
+	return t
+}
+
+func (s *Server) Toaster(id string) (models.Toaster, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.toasters[id]
+	if !ok {
+		return models.Toaster{}, false
+	}
+	return t.Toaster, true
+}
+
+// Files returns the code files of a toaster, by path.
+func (s *Server) Files(id string) map[string][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.toasters[id]
+	if !ok {
+		return nil
+	}
+
+	files := make(map[string][]byte, len(t.files))
+	for k, v := range t.files {
+		files[k] = v
+	}
+	return files
+}
+
+// SetLogs sets the logs returned for an execution of a toaster.
+func (s *Server) SetLogs(id, exeID string, logs []byte) {
+	s.withToaster(id, func(t *toaster) { t.logs[exeID] = logs })
+}
+
+// AppendLogs adds logs to an execution of a toaster, they are delivered to
+// the log streams following it.
+func (s *Server) AppendLogs(id, exeID string, logs []byte) {
+	s.withToaster(id, func(t *toaster) { t.logs[exeID] = append(t.logs[exeID], logs...) })
+}
+
+// SetRunning sets the number of running executions reported for a toaster.
+func (s *Server) SetRunning(id string, running int) {
+	s.withToaster(id, func(t *toaster) { t.running = running })
+}
+
+func (s *Server) SetStats(id string, stats models.ToasterStats) {
+	s.withToaster(id, func(t *toaster) { t.stats = stats })
+}
+
+func (s *Server) withToaster(id string, f func(t *toaster)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.toasters[id]; ok {
+		f(t)
+	}
+}
+
+// ownedToaster returns the toaster with the given ID if it belongs to
+// userID, or answers with a 404 error.
+func (s *Server) ownedToaster(w http.ResponseWriter, id, userID string) *toaster {
+	t, ok := s.toasters[id]
+	if !ok || t.OwnerID != userID {
+		writeError(w, http.StatusNotFound, "toaster_not_found", "toaster "+id+" not found")
+		return nil
+	}
+	return t
+}
+
+func (s *Server) listToasters(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toasters := []models.Toaster{}
+	for _, t := range s.toasters {
+		if t.OwnerID == userID {
+			toasters = append(toasters, t.Toaster)
+		}
+	}
+	sort.Slice(toasters, func(i, j int) bool { return toasters[i].ID < toasters[j].ID })
+
+	writeJSON(w, map[string]interface{}{
+		"success":  true,
+		"toasters": toasters,
+	})
+}
+
+func (s *Server) toasterCount(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/count/"), userID)
+	if t == nil {
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"running": t.running,
+	})
+}
+
+func (s *Server) toasterStats(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/stats/"), userID)
+	if t == nil {
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"stats":   t.stats,
+	})
+}
+
+func (s *Server) listToasterFiles(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/listfiles/"), userID)
+	if t == nil {
+		return
+	}
+
+	files := []string{}
+	for name := range t.files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	resp := map[string]interface{}{
+		"success": true,
+		"files":   files,
+	}
+	if req.Query.Get("hashes") == "true" {
+		hashes := make([]models.FileHash, 0, len(files))
+		for _, name := range files {
+			sum := sha256.Sum256(t.files[name])
+			hashes = append(hashes, models.FileHash{
+				Path:   name,
+				SHA256: hex.EncodeToString(sum[:]),
+				Size:   int64(len(t.files[name])),
+			})
+		}
+		resp["hashes"] = hashes
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) getToasterFile(w http.ResponseWriter, req *Request, userID string) {
+	id, path := splitParam(pathParam(req.Path, "/toaster/file/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, id, userID)
+	if t == nil {
+		return
+	}
+
+	b, ok := t.files[path]
+	if !ok {
+		writeError(w, http.StatusNotFound, "file_not_found", "file "+path+" not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(b)
+}
+
+func (s *Server) getToasterLogs(w http.ResponseWriter, req *Request, userID string) {
+	id, exeID := splitParam(pathParam(req.Path, "/toaster/logs/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, id, userID)
+	if t == nil {
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"logs":    t.logs[exeID],
+	})
+}
+
+func (s *Server) getToaster(w http.ResponseWriter, req *Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/"), userID)
+	if t == nil {
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"toaster": t.Toaster,
+	})
+}
+
+func (s *Server) createToaster(w http.ResponseWriter, req *Request, userID string) {
+	in := &models.Toaster{}
+	code := &codeRequest{}
+	if len(req.Body) > 0 {
+		if err := req.Decode(in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if err := req.Decode(code); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+	}
+
+	files, ok := codeFiles(w, req, code)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &toaster{
+		Toaster: *in,
+		files:   files,
+		logs:    map[string][]byte{},
+	}
+	t.ID = toastcloud.ToasterIDPrefix + s.nextID()
+	t.OwnerID = userID
+	t.Version = 1
+	s.toasters[t.ID] = t
+	b := s.startBuild(t, code.Async)
+
+	resp := map[string]interface{}{
+		"success": true,
+		"toaster": t.Toaster,
+		"domain":  t.ID + DomainSuffix,
+		"build":   b.model(),
+	}
+	if !code.Async {
+		resp["build_logs"] = b.logs
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) updateToaster(w http.ResponseWriter, req *Request, userID string) {
+	in := &updateToasterRequest{}
+	if len(req.Body) > 0 {
+		if err := req.Decode(in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+	}
+
+	files, ok := codeFiles(w, req, &in.codeRequest)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.ownedToaster(w, pathParam(req.Path, "/toaster/"), userID)
+	if t == nil {
+		return
+	}
+
+	switch {
+	case in.Delta:
+		if t.files == nil {
+			t.files = map[string][]byte{}
+		}
+		for _, name := range in.DeletedFiles {
+			delete(t.files, name)
+		}
+		for name, b := range files {
+			t.files[name] = b
+		}
+	case len(files) > 0 || in.GitURL != "":
+		t.files = files
+	}
+	if in.BuildCmd != nil {
+		t.BuildCmd = in.BuildCmd
+	}
+	if in.ExeCmd != nil {
+		t.ExeCmd = in.ExeCmd
+	}
+	if in.Env != nil {
+		t.Env = in.Env
+	}
+	if in.JoinableForSec != nil {
+		t.JoinableForSec = *in.JoinableForSec
+	}
+	if in.MaxConcurrentJoiners != nil {
+		t.MaxConcurrentJoiners = *in.MaxConcurrentJoiners
+	}
+	if in.TimeoutSec != nil {
+		t.TimeoutSec = *in.TimeoutSec
+	}
+	if in.Name != nil {
+		t.Name = *in.Name
+	}
+	if in.Readme != nil {
+		t.Readme = *in.Readme
+	}
+	if in.Keywords != nil {
+		t.Keywords = in.Keywords
+	}
+	t.Version++
+	b := s.startBuild(t, in.Async)
+
+	resp := map[string]interface{}{
+		"success": true,
+		"toaster": t.Toaster,
+		"domain":  t.ID + DomainSuffix,
+		"build":   b.model(),
+	}
+	if !in.Async {
+		resp["build_logs"] = b.logs
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) deleteToasters(w http.ResponseWriter, req *Request, userID string) {
+	in := &struct {
+		IDs []string `json:"toaster_ids"`
+	}{}
+	if err := req.Decode(in); err != nil || len(in.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "toaster_ids is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range in.IDs {
+		if s.ownedToaster(w, id, userID) == nil {
+			return
+		}
+	}
+	for _, id := range in.IDs {
+		delete(s.toasters, id)
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// codeFiles returns the code files sent with a create or update request,
+// either inline in the JSON body or as multipart files.
+func codeFiles(w http.ResponseWriter, req *Request, code *codeRequest) (map[string][]byte, bool) {
+	if req.Archive != nil {
+		files, err := extractArchive(req.Archive, code.ArchiveFormat)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_archive", err.Error())
+			return nil, false
+		}
+		return files, true
+	}
+	if req.Files != nil {
+		return req.Files, true
+	}
+
+	if len(code.Codes) != len(code.CodePaths) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "codes and code_paths must have the same length")
+		return nil, false
+	}
+
+	files := map[string][]byte{}
+	for i, path := range code.CodePaths {
+		files[path] = code.Codes[i]
+	}
+	return files, true
+}
+
+func buildLogs(t *toaster) []byte {
+	return []byte("toastcloudtest: built " + t.ID + " with " + strings.Join(t.BuildCmd, " ") + "\n")
+}
+
+// splitParam splits "id/rest" path parameters.
+func splitParam(param string) (string, string) {
+	i := strings.IndexByte(param, '/')
+	if i < 0 {
+		return param, ""
+	}
+	return param[:i], param[i+1:]
+}
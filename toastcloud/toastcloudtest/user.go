@@ -0,0 +1,61 @@
+package toastcloudtest
+
+import (
+	"net/http"
+)
+
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (s *Server) signup(w http.ResponseWriter, req *Request, userID string) {
+	in := &credentialsRequest{}
+	if err := req.Decode(in); err != nil || in.Email == "" || in.Password == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "email and password are required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[in.Email]; ok {
+		writeError(w, http.StatusConflict, "email_taken", "an account already exists for this email")
+		return
+	}
+	u := s.addUser(in.Email, in.Password)
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"user":    u.User,
+	})
+}
+
+func (s *Server) signin(w http.ResponseWriter, req *Request, userID string) {
+	in := &credentialsRequest{}
+	if err := req.Decode(in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[in.Email]
+	if !ok || u.password != in.Password {
+		writeError(w, http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"token":   s.newToken(u.ID),
+	})
+}
+
+func (s *Server) setupBilling(w http.ResponseWriter, req *Request, userID string) {
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"url":     s.URL + "/billing/" + userID,
+	})
+}
@@ -1,8 +1,11 @@
 package toastcloud
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"time"
 
 	"github.com/toastate/toastate-sdk-go/common/models"
 	"github.com/toastate/toastate-sdk-go/internal/apiclient"
@@ -22,22 +25,26 @@ type toasterCountResponse struct {
 }
 
 func (sess *Session) ToasterCount(input *ToasterCountInput) (*ToasterCountOutput, error) {
+	return sess.ToasterCountWithContext(context.Background(), input)
+}
+
+func (sess *Session) ToasterCountWithContext(ctx context.Context, input *ToasterCountInput) (*ToasterCountOutput, error) {
 	resp := &toasterCountResponse{}
 
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster")
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
-	apierr, err := sess.client.AuthedGet("/toaster/count/"+input.ID, resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/count/"+input.ID, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &ToasterCountOutput{
@@ -45,6 +52,90 @@ func (sess *Session) ToasterCount(input *ToasterCountInput) (*ToasterCountOutput
 	}, nil
 }
 
+type DrainToasterInput struct {
+	ID string `json:"id,omitempty"`
+
+	// Timeout bounds how long DrainToaster waits for the running executions
+	// to finish. It waits until the context is done when zero.
+	Timeout time.Duration `json:"-"`
+
+	// PollInterval is how often ToasterCount is checked. Defaults to
+	// DefaultExecutionPollInterval.
+	PollInterval time.Duration `json:"-"`
+}
+
+type DrainToasterOutput struct {
+	// Running is the number of executions still running when DrainToaster
+	// returned, 0 unless Timeout was reached.
+	Running int `json:"running,omitempty"`
+	// Drained is true when no execution is running anymore.
+	Drained bool `json:"drained,omitempty"`
+}
+
+type drainToasterResponse struct {
+	Success bool `json:"success"`
+}
+
+func (sess *Session) DrainToaster(input *DrainToasterInput) (*DrainToasterOutput, error) {
+	return sess.DrainToasterWithContext(context.Background(), input)
+}
+
+// DrainToasterWithContext stops the running executions of a toaster from
+// accepting new joiners, then waits for them to finish, as reported by
+// ToasterCount. Requests that do not join still start new executions.
+func (sess *Session) DrainToasterWithContext(ctx context.Context, input *DrainToasterInput) (*DrainToasterOutput, error) {
+	resp := &drainToasterResponse{}
+
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+
+	interval := input.PollInterval
+	if interval <= 0 {
+		interval = DefaultExecutionPollInterval
+	}
+
+	apierr, err := sess.client.AuthedPost(ctx, "/toaster/drain/"+input.ID, nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	if apierr != nil {
+		return nil, newAPIError(apierr)
+	}
+
+	if !resp.Success {
+		return nil, ErrUnexpectedFailure
+	}
+
+	var deadline <-chan time.Time
+	if input.Timeout > 0 {
+		timer := time.NewTimer(input.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := sess.ToasterCountWithContext(ctx, &ToasterCountInput{ID: input.ID})
+		if err != nil {
+			return nil, err
+		}
+		if count.Running == 0 {
+			return &DrainToasterOutput{Drained: true}, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return &DrainToasterOutput{Running: count.Running}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
 type ToasterStatsInput struct {
 	ID string `json:"id,omitempty"`
 }
@@ -59,26 +150,30 @@ type toasterStatsResponse struct {
 }
 
 func (sess *Session) ToasterStats(input *ToasterStatsInput) (*ToasterStatsOutput, error) {
+	return sess.ToasterStatsWithContext(context.Background(), input)
+}
+
+func (sess *Session) ToasterStatsWithContext(ctx context.Context, input *ToasterStatsInput) (*ToasterStatsOutput, error) {
 	resp := &toasterStatsResponse{}
 
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster")
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
-	apierr, err := sess.client.AuthedGet("/toaster/stats/"+input.ID, resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/stats/"+input.ID, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.Stats == nil {
-		return nil, fmt.Errorf("The request was successfull but the remote API returned an empty body")
+		return nil, ErrEmptyResponse
 	}
 
 	return &ToasterStatsOutput{
@@ -100,26 +195,30 @@ type getToasterResponse struct {
 }
 
 func (sess *Session) GetToaster(input *GetToasterInput) (*GetToasterOutput, error) {
+	return sess.GetToasterWithContext(context.Background(), input)
+}
+
+func (sess *Session) GetToasterWithContext(ctx context.Context, input *GetToasterInput) (*GetToasterOutput, error) {
 	resp := &getToasterResponse{}
 
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster to get")
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
-	apierr, err := sess.client.AuthedGet("/toaster/"+input.ID, resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/"+input.ID, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.Toaster == nil {
-		return nil, fmt.Errorf("The request was successfull but the remote API returned an empty body")
+		return nil, ErrEmptyResponse
 	}
 
 	return &GetToasterOutput{
@@ -137,8 +236,12 @@ type GetToasterFileOutput struct {
 }
 
 func (sess *Session) GetToasterFile(input *GetToasterFileInput) (*GetToasterFileOutput, error) {
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster")
+	return sess.GetToasterFileWithContext(context.Background(), input)
+}
+
+func (sess *Session) GetToasterFileWithContext(ctx context.Context, input *GetToasterFileInput) (*GetToasterFileOutput, error) {
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
 	if len(input.Path) == 0 {
@@ -157,12 +260,12 @@ func (sess *Session) GetToasterFile(input *GetToasterFileInput) (*GetToasterFile
 		return nil, fmt.Errorf("you did not provide the Path of the file to retrieve")
 	}
 
-	file, apierr, err := sess.client.AuthedStreamedGet("/toaster/file/" + input.ID + "/" + input.Path)
+	file, apierr, err := sess.client.AuthedStreamedGet(ctx, "/toaster/file/"+input.ID+"/"+input.Path)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	return &GetToasterFileOutput{
@@ -172,43 +275,59 @@ func (sess *Session) GetToasterFile(input *GetToasterFileInput) (*GetToasterFile
 
 type ListToasterFilesInput struct {
 	ID string `json:"id,omitempty"`
+
+	// WithHashes also returns the content hash of every file in Hashes.
+	WithHashes bool `json:"with_hashes,omitempty"`
 }
 
 type ListToasterFilesOutput struct {
-	Files []string `json:"files,omitempty"`
+	Files  []string          `json:"files,omitempty"`
+	Hashes []models.FileHash `json:"hashes,omitempty"`
 }
 
 type listToasterFilesResponse struct {
-	Success bool     `json:"success"`
-	Files   []string `json:"files,omitempty"`
+	Success bool              `json:"success"`
+	Files   []string          `json:"files,omitempty"`
+	Hashes  []models.FileHash `json:"hashes,omitempty"`
 }
 
 func (sess *Session) ListToasterFiles(input *ListToasterFilesInput) (*ListToasterFilesOutput, error) {
+	return sess.ListToasterFilesWithContext(context.Background(), input)
+}
+
+func (sess *Session) ListToasterFilesWithContext(ctx context.Context, input *ListToasterFilesInput) (*ListToasterFilesOutput, error) {
 	resp := &listToasterFilesResponse{}
 
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster to get")
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
-	apierr, err := sess.client.AuthedGet("/toaster/listfiles/"+input.ID, resp)
+	path := "/toaster/listfiles/" + input.ID
+	if input.WithHashes {
+		path += "?hashes=true"
+	}
+
+	apierr, err := sess.client.AuthedGet(ctx, path, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &ListToasterFilesOutput{
-		Files: resp.Files,
+		Files:  resp.Files,
+		Hashes: resp.Hashes,
 	}, nil
 }
 
 type GetToasterLogsInput struct {
-	ID    string `json:"id,omitempty"`
+	ID string `json:"id,omitempty"`
+	// ExeID is the ID of a normal (ex_) or forced (fex_) execution.
 	ExeID string `json:"exe_id,omitempty"`
 }
 
@@ -222,22 +341,31 @@ type getToasterLogsResponse struct {
 }
 
 func (sess *Session) GetToasterLogs(input *GetToasterLogsInput) (*GetToasterLogsOutput, error) {
+	return sess.GetToasterLogsWithContext(context.Background(), input)
+}
+
+func (sess *Session) GetToasterLogsWithContext(ctx context.Context, input *GetToasterLogsInput) (*GetToasterLogsOutput, error) {
 	resp := &getToasterLogsResponse{}
 
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster to get")
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
+	}
+	if input.ExeID != "" {
+		if err := ExecutionID(input.ExeID).Validate(); err != nil {
+			return nil, err
+		}
 	}
 
-	apierr, err := sess.client.AuthedGet("/toaster/logs/"+input.ID+"/"+input.ExeID, resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/logs/"+input.ID+"/"+input.ExeID, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &GetToasterLogsOutput{
@@ -258,18 +386,22 @@ type listToastersResponse struct {
 }
 
 func (sess *Session) ListToasters(input *ListToastersInput) (*ListToastersOutput, error) {
+	return sess.ListToastersWithContext(context.Background(), input)
+}
+
+func (sess *Session) ListToastersWithContext(ctx context.Context, input *ListToastersInput) (*ListToastersOutput, error) {
 	resp := &listToastersResponse{}
 
-	apierr, err := sess.client.AuthedGet("/toaster/list", resp)
+	apierr, err := sess.client.AuthedGet(ctx, "/toaster/list", resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &ListToastersOutput{
@@ -289,18 +421,28 @@ type deleteToasterResponse struct {
 }
 
 func (sess *Session) DeleteToaster(input *DeleteToasterInput) (*DeleteToasterOutput, error) {
+	return sess.DeleteToasterWithContext(context.Background(), input)
+}
+
+func (sess *Session) DeleteToasterWithContext(ctx context.Context, input *DeleteToasterInput) (*DeleteToasterOutput, error) {
 	resp := &deleteToasterResponse{}
 
-	apierr, err := sess.client.AuthedDelete("/toaster", input, resp)
+	for _, id := range input.IDs {
+		if err := ToasterID(id).Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	apierr, err := sess.client.AuthedDelete(ctx, "/toaster", input, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	return &DeleteToasterOutput{}, nil
@@ -314,14 +456,42 @@ type CreateToasterInput struct {
 	// OR
 	CodeFolder string `json:"code_folder,omitempty"`
 	// OR
+	// CodeFS is uploaded from CodeFSRoot, its root by default, as is done
+	// for CodeFolder. It can be an embed.FS, a fstest.MapFS or an os.DirFS.
+	CodeFS     fs.FS  `json:"-"`
+	CodeFSRoot string `json:"code_fs_root,omitempty"`
+
+	// Files of CodeFolder or CodeFS are skipped following their .toastignore
+	// files, and their .gitignore files when CodeFolderGitignore is set.
+	// Include and Exclude are gitignore patterns relative to their root. Use
+	// CodeFolderFiles to list the files that are uploaded.
+	CodeFolderGitignore bool     `json:"code_folder_gitignore,omitempty"`
+	CodeFolderInclude   []string `json:"code_folder_include,omitempty"`
+	CodeFolderExclude   []string `json:"code_folder_exclude,omitempty"`
+	// OR
 	CodeStream chan *models.MultipartItem `json:"code_stream,omitempty"`
 	// OR
+	// CodeArchive is a prebuilt archive of the code, in CodeArchiveFormat.
+	// Its upload can not be retried.
+	CodeArchive io.Reader `json:"-"`
+	// OR
+	// CodeArchiveFile is the path of a prebuilt archive of the code. Its
+	// format is told by its extension unless CodeArchiveFormat is set.
+	CodeArchiveFile string `json:"code_archive_file,omitempty"`
+	// OR
 	GitURL         string `json:"git_url,omitempty"`
 	GitUsername    string `json:"git_username,omitempty"`
 	GitAccessToken string `json:"git_access_token,omitempty"`
 	GitPassword    string `json:"git_password,omitempty"`
 	GitBranch      string `json:"git_branch,omitempty"`
 
+	// CodeArchiveFormat packs the files of CodeFolder, CodeFS or CodeStream
+	// in a single compressed archive, instead of sending them one by one.
+	// Files are archived in lexical order for CodeFolder and CodeFS, in the
+	// order they are received for CodeStream, all with the same modification
+	// time.
+	CodeArchiveFormat ArchiveFormat `json:"code_archive_format,omitempty"`
+
 	// Executed in the root directory of the codepaths
 	BuildCmd []string `json:"build_command,omitempty"`
 	ExeCmd   []string `json:"execution_command,omitempty"`
@@ -334,6 +504,21 @@ type CreateToasterInput struct {
 	Name     string   `json:"name,omitempty"`
 	Readme   string   `json:"readme,omitempty"`
 	Keywords []string `json:"keywords,omitempty"`
+
+	// Async makes CreateToaster return as soon as the build started, see
+	// WaitForBuild.
+	Async bool `json:"async,omitempty"`
+
+	// BuildLogWriter, when set, receives the output of the build as it is
+	// produced. It is ignored when Async is set, see StreamBuildLogs. When
+	// following the build fails, the output is returned along with the
+	// error, its Build being the one that was started.
+	BuildLogWriter io.Writer `json:"-"`
+
+	// UploadProgress, when set, is called as the code of CodeFolder, CodeFS,
+	// CodeStream or CodeArchive is uploaded, from the goroutine sending it.
+	// It is called again from the start when the upload is retried.
+	UploadProgress func(p UploadProgress) `json:"-"`
 }
 
 type createToasterRequest struct {
@@ -347,6 +532,9 @@ type createToasterRequest struct {
 	GitPassword    string   `json:"git_password,omitempty"`
 	GitBranch      string   `json:"git_branch,omitempty"`
 
+	// ArchiveFormat is set when the code is sent as an archive.
+	ArchiveFormat ArchiveFormat `json:"archive_format,omitempty"`
+
 	// Executed in the root directory where the codepaths have been put
 	BuildCmd []string `json:"build_command,omitempty"`
 	ExeCmd   []string `json:"execution_command,omitempty"`
@@ -359,12 +547,18 @@ type createToasterRequest struct {
 	Name     string   `json:"name,omitempty"`
 	Readme   string   `json:"readme,omitempty"`
 	Keywords []string `json:"keywords,omitempty"`
+
+	Async bool `json:"async,omitempty"`
 }
 
 type CreateToasterOutput struct {
 	Toaster   *models.Toaster `json:"toaster,omitempty"`
 	Domain    string          `json:"domain,omitempty"`
 	BuildLogs []byte          `json:"build_logs,omitempty"`
+
+	// Build is the build of the new version of the toaster. Unless the
+	// input was Async, it is done.
+	Build *models.Build `json:"build,omitempty"`
 }
 
 type createToasterResponse struct {
@@ -372,9 +566,14 @@ type createToasterResponse struct {
 	Toaster   *models.Toaster `json:"toaster,omitempty"`
 	Domain    string          `json:"domain,omitempty"`
 	BuildLogs []byte          `json:"build_logs,omitempty"`
+	Build     *models.Build   `json:"build,omitempty"`
 }
 
 func (sess *Session) CreateToaster(input *CreateToasterInput) (*CreateToasterOutput, error) {
+	return sess.CreateToasterWithContext(context.Background(), input)
+}
+
+func (sess *Session) CreateToasterWithContext(ctx context.Context, input *CreateToasterInput) (*CreateToasterOutput, error) {
 	resp := &createToasterResponse{}
 	req := &createToasterRequest{
 		CryptoSecure:         input.CryptoSecure,
@@ -388,6 +587,8 @@ func (sess *Session) CreateToaster(input *CreateToasterInput) (*CreateToasterOut
 		Readme:               input.Readme,
 		Keywords:             input.Keywords,
 	}
+	// Following the build requires it to be asynchronous.
+	req.Async = input.Async || input.BuildLogWriter != nil
 
 	var err error
 	var apierr *apiclient.Error
@@ -395,42 +596,87 @@ func (sess *Session) CreateToaster(input *CreateToasterInput) (*CreateToasterOut
 	case len(input.CodePaths) > 0:
 		req.Codes = input.Codes
 		req.CodePaths = input.CodePaths
-		apierr, err = sess.client.AuthedPost("/toaster", req, resp)
+		apierr, err = sess.client.AuthedPost(ctx, "/toaster", req, resp)
 	case input.GitURL != "":
 		req.GitURL = input.GitURL
 		req.GitUsername = input.GitUsername
 		req.GitAccessToken = input.GitAccessToken
 		req.GitPassword = input.GitPassword
 		req.GitBranch = input.GitBranch
-		apierr, err = sess.client.AuthedPost("/toaster", req, resp)
-	case input.CodeFolder != "":
-		apierr, err = sess.client.AuthedMultipartFolderPost(input.CodeFolder, "/toaster", req, resp)
+		apierr, err = sess.client.AuthedPost(ctx, "/toaster", req, resp)
+	case input.CodeFolder != "" || input.CodeFS != nil:
+		var fsys fs.FS
+		fsys, err = codeFS(input.CodeFolder, input.CodeFS, input.CodeFSRoot)
+		if err != nil {
+			return nil, err
+		}
+		req.ArchiveFormat = input.CodeArchiveFormat
+		folder := &apiclient.Folder{
+			FS:       fsys,
+			Ignore:   input.codeFolderOptions(),
+			Archive:  input.CodeArchiveFormat,
+			Progress: input.UploadProgress,
+		}
+		apierr, err = sess.client.AuthedMultipartFolderPost(ctx, folder, "/toaster", req, resp)
 	case input.CodeStream != nil:
-		apierr, err = sess.client.AuthedMultipartReadersPost(input.CodeStream, "/toaster", req, resp)
+		req.ArchiveFormat = input.CodeArchiveFormat
+		stream := &apiclient.Stream{
+			Items:    input.CodeStream,
+			Archive:  input.CodeArchiveFormat,
+			Progress: input.UploadProgress,
+		}
+		apierr, err = sess.client.AuthedMultipartReadersPost(ctx, stream, "/toaster", req, resp)
+	case input.CodeArchive != nil || input.CodeArchiveFile != "":
+		var archive *apiclient.Archive
+		archive, err = newCodeArchive(input.CodeArchive, input.CodeArchiveFile, input.CodeArchiveFormat)
+		if err != nil {
+			return nil, err
+		}
+		archive.Progress = input.UploadProgress
+		req.ArchiveFormat = archive.Format
+		apierr, err = sess.client.AuthedArchivePost(ctx, archive, "/toaster", req, resp)
 	default:
-		apierr, err = sess.client.AuthedPost("/toaster", req, resp)
+		apierr, err = sess.client.AuthedPost(ctx, "/toaster", req, resp)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.Toaster == nil {
-		return nil, fmt.Errorf("The request was successfull but the remote API returned an empty body")
+		return nil, ErrEmptyResponse
 	}
 
-	return &CreateToasterOutput{
+	out := &CreateToasterOutput{
 		Toaster:   resp.Toaster,
 		Domain:    resp.Domain,
 		BuildLogs: resp.BuildLogs,
-	}, nil
+		Build:     resp.Build,
+	}
+
+	if req.Async && resp.Build == nil {
+		// The toaster was created, only its build is missing.
+		return out, ErrEmptyResponse
+	}
+
+	if req.Async && !input.Async {
+		build, err := sess.followBuild(ctx, resp.Toaster.ID, resp.Build, input.BuildLogWriter)
+		if err != nil {
+			// The toaster exists: keep its output so that it is not lost.
+			return out, err
+		}
+		out.Build = build.Build
+		out.BuildLogs = build.BuildLogs
+	}
+
+	return out, nil
 }
 
 type UpdateToasterInput struct {
@@ -441,8 +687,33 @@ type UpdateToasterInput struct {
 	// OR
 	CodeFolder string `json:"code_folder,omitempty"`
 	// OR
+	// CodeFS is uploaded from CodeFSRoot, its root by default, as is done
+	// for CodeFolder. It can be an embed.FS, a fstest.MapFS or an os.DirFS.
+	CodeFS     fs.FS  `json:"-"`
+	CodeFSRoot string `json:"code_fs_root,omitempty"`
+
+	// Files of CodeFolder or CodeFS are skipped following their .toastignore
+	// files, and their .gitignore files when CodeFolderGitignore is set.
+	// Include and Exclude are gitignore patterns relative to their root. Use
+	// CodeFolderFiles to list the files that are uploaded.
+	CodeFolderGitignore bool     `json:"code_folder_gitignore,omitempty"`
+	CodeFolderInclude   []string `json:"code_folder_include,omitempty"`
+	CodeFolderExclude   []string `json:"code_folder_exclude,omitempty"`
+	// CodeFolderDelta only uploads the files of CodeFolder or CodeFS that
+	// are not deployed with the same content, and deletes the deployed files
+	// they do not have anymore. See UpdateToasterOutput.Delta.
+	CodeFolderDelta bool `json:"code_folder_delta,omitempty"`
+	// OR
 	CodeStream chan *models.MultipartItem `json:"code_stream,omitempty"`
 	// OR
+	// CodeArchive is a prebuilt archive of the code, in CodeArchiveFormat.
+	// Its upload can not be retried.
+	CodeArchive io.Reader `json:"-"`
+	// OR
+	// CodeArchiveFile is the path of a prebuilt archive of the code. Its
+	// format is told by its extension unless CodeArchiveFormat is set.
+	CodeArchiveFile string `json:"code_archive_file,omitempty"`
+	// OR
 	GitURL         string `json:"git_url,omitempty"`
 	GitUsername    string `json:"git_username,omitempty"`
 	GitAccessToken string `json:"git_access_token,omitempty"`
@@ -450,6 +721,9 @@ type UpdateToasterInput struct {
 	GitBranch      string `json:"git_branch,omitempty"`
 	GitRefresh     bool   `json:"refresh_from_last_git,omitempty"`
 
+	// CodeArchiveFormat works as in CreateToasterInput.
+	CodeArchiveFormat ArchiveFormat `json:"code_archive_format,omitempty"`
+
 	// Executed in the root directory of the codepaths
 	BuildCmd []string `json:"build_command,omitempty"`
 	ExeCmd   []string `json:"execution_command,omitempty"`
@@ -462,6 +736,21 @@ type UpdateToasterInput struct {
 	Name     *string  `json:"name,omitempty"`
 	Readme   *string  `json:"readme,omitempty"`
 	Keywords []string `json:"keywords,omitempty"`
+
+	// Async makes UpdateToaster return as soon as the build started, see
+	// WaitForBuild.
+	Async bool `json:"async,omitempty"`
+
+	// BuildLogWriter, when set, receives the output of the build as it is
+	// produced. It is ignored when Async is set, see StreamBuildLogs. When
+	// following the build fails, the output is returned along with the
+	// error, its Build being the one that was started.
+	BuildLogWriter io.Writer `json:"-"`
+
+	// UploadProgress, when set, is called as the code of CodeFolder, CodeFS,
+	// CodeStream or CodeArchive is uploaded, from the goroutine sending it.
+	// It is called again from the start when the upload is retried.
+	UploadProgress func(p UploadProgress) `json:"-"`
 }
 
 type updateToasterRequest struct {
@@ -477,6 +766,8 @@ type updateToasterRequest struct {
 	Readme   *string  `json:"readme,omitempty" bson:"readme,omitempty"`
 	Keywords []string `json:"keywords,omitempty" bson:"keywords,omitempty"`
 
+	Async bool `json:"async,omitempty"`
+
 	Codes          [][]byte `json:"codes,omitempty"`
 	CodePaths      []string `json:"code_paths,omitempty"`
 	GitURL         *string  `json:"git_url,omitempty"`
@@ -485,12 +776,28 @@ type updateToasterRequest struct {
 	GitPassword    *string  `json:"git_password,omitempty"`
 	GitBranch      *string  `json:"git_branch,omitempty"`
 	GitRefresh     bool     `json:"refresh_from_last_git,omitempty"`
+
+	ArchiveFormat ArchiveFormat `json:"archive_format,omitempty"`
+
+	// Delta keeps the deployed files that are not uploaded, except for
+	// DeletedFiles.
+	Delta        bool     `json:"delta,omitempty"`
+	DeletedFiles []string `json:"deleted_files,omitempty"`
 }
 
 type UpdateToasterOutput struct {
 	Toaster   *models.Toaster `json:"toaster,omitempty"`
 	Domain    string          `json:"domain,omitempty"`
 	BuildLogs []byte          `json:"build_logs,omitempty"`
+
+	// Delta reports what was uploaded when CodeFolderDelta was set. It is
+	// nil when the API did not report the hashes of the deployed files, the
+	// whole folder being uploaded instead.
+	Delta *DeltaUpload `json:"delta,omitempty"`
+
+	// Build is the build of the new version of the toaster. Unless the
+	// input was Async, it is done.
+	Build *models.Build `json:"build,omitempty"`
 }
 
 type updateToasterResponse struct {
@@ -498,11 +805,16 @@ type updateToasterResponse struct {
 	Toaster   *models.Toaster `json:"toaster,omitempty"`
 	Domain    string          `json:"domain,omitempty"`
 	BuildLogs []byte          `json:"build_logs,omitempty"`
+	Build     *models.Build   `json:"build,omitempty"`
 }
 
 func (sess *Session) UpdateToaster(input *UpdateToasterInput) (*UpdateToasterOutput, error) {
-	if input.ID == "" {
-		return nil, fmt.Errorf("you did not provide the ID of the Toaster to update")
+	return sess.UpdateToasterWithContext(context.Background(), input)
+}
+
+func (sess *Session) UpdateToasterWithContext(ctx context.Context, input *UpdateToasterInput) (*UpdateToasterOutput, error) {
+	if err := ToasterID(input.ID).Validate(); err != nil {
+		return nil, err
 	}
 
 	resp := &updateToasterResponse{}
@@ -518,47 +830,109 @@ func (sess *Session) UpdateToaster(input *UpdateToasterInput) (*UpdateToasterOut
 		Keywords:             input.Keywords,
 		GitRefresh:           input.GitRefresh,
 	}
+	// Following the build requires it to be asynchronous.
+	req.Async = input.Async || input.BuildLogWriter != nil
 
 	var err error
 	var apierr *apiclient.Error
+	var delta *DeltaUpload
 	switch {
 	case len(input.CodePaths) > 0:
 		req.Codes = input.Codes
 		req.CodePaths = input.CodePaths
-		apierr, err = sess.client.AuthedPut("/toaster/"+input.ID, req, resp)
+		apierr, err = sess.client.AuthedPut(ctx, "/toaster/"+input.ID, req, resp)
 	case input.GitURL != "":
 		req.GitURL = &input.GitURL
 		req.GitUsername = &input.GitUsername
 		req.GitAccessToken = &input.GitAccessToken
 		req.GitPassword = &input.GitPassword
 		req.GitBranch = &input.GitBranch
-		apierr, err = sess.client.AuthedPut("/toaster/"+input.ID, req, resp)
-	case input.CodeFolder != "":
-		apierr, err = sess.client.AuthedMultipartFolderPut(input.CodeFolder, "/toaster/"+input.ID, req, resp)
+		apierr, err = sess.client.AuthedPut(ctx, "/toaster/"+input.ID, req, resp)
+	case input.CodeFolder != "" || input.CodeFS != nil:
+		var fsys fs.FS
+		fsys, err = codeFS(input.CodeFolder, input.CodeFS, input.CodeFSRoot)
+		if err != nil {
+			return nil, err
+		}
+		req.ArchiveFormat = input.CodeArchiveFormat
+		folder := &apiclient.Folder{
+			FS:       fsys,
+			Ignore:   input.codeFolderOptions(),
+			Archive:  input.CodeArchiveFormat,
+			Progress: input.UploadProgress,
+		}
+		if input.CodeFolderDelta {
+			var upload map[string]bool
+			delta, upload, err = sess.codeDelta(ctx, input.ID, folder)
+			if err != nil {
+				return nil, err
+			}
+			if delta != nil {
+				folder.Filter = func(name string) bool { return upload[name] }
+				req.Delta = true
+				req.DeletedFiles = delta.Deleted
+			}
+		}
+		apierr, err = sess.client.AuthedMultipartFolderPut(ctx, folder, "/toaster/"+input.ID, req, resp)
 	case input.CodeStream != nil:
-		apierr, err = sess.client.AuthedMultipartReadersPut(input.CodeStream, "/toaster/"+input.ID, req, resp)
+		req.ArchiveFormat = input.CodeArchiveFormat
+		stream := &apiclient.Stream{
+			Items:    input.CodeStream,
+			Archive:  input.CodeArchiveFormat,
+			Progress: input.UploadProgress,
+		}
+		apierr, err = sess.client.AuthedMultipartReadersPut(ctx, stream, "/toaster/"+input.ID, req, resp)
+	case input.CodeArchive != nil || input.CodeArchiveFile != "":
+		var archive *apiclient.Archive
+		archive, err = newCodeArchive(input.CodeArchive, input.CodeArchiveFile, input.CodeArchiveFormat)
+		if err != nil {
+			return nil, err
+		}
+		archive.Progress = input.UploadProgress
+		req.ArchiveFormat = archive.Format
+		apierr, err = sess.client.AuthedArchivePut(ctx, archive, "/toaster/"+input.ID, req, resp)
 	default:
-		apierr, err = sess.client.AuthedPut("/toaster/"+input.ID, req, resp)
+		apierr, err = sess.client.AuthedPut(ctx, "/toaster/"+input.ID, req, resp)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.Toaster == nil {
-		return nil, fmt.Errorf("The request was successfull but the remote API returned an empty body")
+		return nil, ErrEmptyResponse
 	}
 
-	return &UpdateToasterOutput{
+	out := &UpdateToasterOutput{
 		Toaster:   resp.Toaster,
 		Domain:    resp.Domain,
 		BuildLogs: resp.BuildLogs,
-	}, nil
+		Build:     resp.Build,
+		Delta:     delta,
+	}
+
+	if req.Async && resp.Build == nil {
+		// The toaster was updated, only its build is missing.
+		return out, ErrEmptyResponse
+	}
+
+	if req.Async && !input.Async {
+		build, err := sess.followBuild(ctx, input.ID, resp.Build, input.BuildLogWriter)
+		if err != nil {
+			// The toaster was updated: keep its output so that it is not
+			// lost.
+			return out, err
+		}
+		out.Build = build.Build
+		out.BuildLogs = build.BuildLogs
+	}
+
+	return out, nil
 }
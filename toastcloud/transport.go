@@ -0,0 +1,171 @@
+package toastcloud
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type ToasterTransportInput struct {
+	// ID of the toaster to send requests to, OR
+	ID string
+	// Domain it is served at, e.g. CreateToasterOutput.Domain.
+	Domain string
+
+	// Authenticated attaches the credentials of the session, its session
+	// token or API key, to requests. They are then readable by the code of
+	// the toaster, so only set it for toasters you trust with them. It is
+	// refused for domains outside of the toaster domain of the session, such
+	// as custom domains.
+	Authenticated bool
+
+	// Sticky pins requests to the execution that served the first one, for
+	// as long as it can be joined. When it can not be anymore, the request is
+	// sent again to a new execution which is pinned in turn.
+	Sticky bool
+}
+
+// ToasterTransport is an http.RoundTripper sending requests to a toaster,
+// whatever the scheme and host of their URL: relative URLs such as "/path"
+// can be used. It is safe for concurrent use.
+type ToasterTransport struct {
+	sess          *Session
+	scheme, host  string
+	authenticated bool
+	sticky        bool
+
+	mu     sync.Mutex
+	last   string
+	pinned string
+}
+
+func (sess *Session) NewToasterTransport(input *ToasterTransportInput) (*ToasterTransport, error) {
+	base, err := sess.toasterURL(input.ID, input.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", base, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Authenticated && !sess.isToasterHost(req.URL.Host) {
+		return nil, fmt.Errorf("credentials are only sent to toasters served under %s, not to %s", sess.toasterDomain, req.URL.Host)
+	}
+
+	return &ToasterTransport{
+		sess:          sess,
+		scheme:        req.URL.Scheme,
+		host:          req.URL.Host,
+		authenticated: input.Authenticated,
+		sticky:        input.Sticky,
+	}, nil
+}
+
+// isToasterHost returns whether host is the domain of a toaster under the
+// toaster domain of the session. The port of host, if any, must be the one
+// of the toaster domain.
+func (sess *Session) isToasterHost(host string) bool {
+	host = strings.ToLower(host)
+	id := strings.TrimSuffix(host, "."+strings.ToLower(sess.toasterDomain))
+	return id != host && id != "" && !strings.Contains(id, ".")
+}
+
+// NewToasterHTTPClient returns an HTTP client sending its requests to a
+// toaster through a ToasterTransport.
+func (sess *Session) NewToasterHTTPClient(input *ToasterTransportInput) (*http.Client, error) {
+	t, err := sess.NewToasterTransport(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: t,
+	}, nil
+}
+
+// ExecutionID returns the ID of the execution that served the last request.
+func (t *ToasterTransport) ExecutionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.last
+}
+
+// PinnedExecutionID returns the ID of the execution requests are pinned to,
+// if any.
+func (t *ToasterTransport) PinnedExecutionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.pinned
+}
+
+// Unpin lets the next request start or join any execution.
+func (t *ToasterTransport) Unpin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pinned = ""
+}
+
+func (t *ToasterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.scheme
+	req.URL.Host = t.host
+	req.Host = ""
+
+	pinned := ""
+	if t.sticky && req.Header.Get(ExecutionIDHeader) == "" && req.Header.Get(ForceNewExecutionHeader) == "" {
+		pinned = t.PinnedExecutionID()
+		if pinned != "" {
+			req.Header.Set(ExecutionIDHeader, pinned)
+		}
+	}
+
+	response, err := t.sess.client.RoundTrip(req, t.authenticated)
+	if err != nil {
+		return nil, err
+	}
+
+	if pinned != "" && JoinStatus(response.Header.Get(JoinStatusHeader)).err() != nil {
+		t.mu.Lock()
+		if t.pinned == pinned {
+			t.pinned = ""
+		}
+		t.mu.Unlock()
+
+		// The body can only be sent again if it can be rewound.
+		if req.Body == nil || req.GetBody != nil {
+			retry := req.Clone(req.Context())
+			retry.Header.Del(ExecutionIDHeader)
+			if req.GetBody != nil {
+				retry.Body, err = req.GetBody()
+				if err != nil {
+					response.Body.Close()
+					return nil, err
+				}
+			}
+
+			response.Body.Close()
+			response, err = t.sess.client.RoundTrip(retry, t.authenticated)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	exeID := response.Header.Get(ExecutionIDHeader)
+	if exeID != "" {
+		t.mu.Lock()
+		t.last = exeID
+		if t.sticky && t.pinned == "" && ExecutionKindOf(exeID) == ExecutionKindNormal {
+			t.pinned = exeID
+		}
+		t.mu.Unlock()
+	}
+
+	return response, nil
+}
@@ -0,0 +1,193 @@
+package toastcloud_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/toastate/toastate-sdk-go/common/models"
+	"github.com/toastate/toastate-sdk-go/toastcloud"
+	"github.com/toastate/toastate-sdk-go/toastcloud/toastcloudtest"
+)
+
+func TestToasterTransportAuthenticated(t *testing.T) {
+	tests := []struct {
+		name string
+		// toasterDomain, when set, is the toaster domain of the session.
+		toasterDomain string
+		input         func(id string) *toastcloud.ToasterTransportInput
+		refused       bool
+	}{
+		{
+			name: "toaster id",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{ID: id, Authenticated: true}
+			},
+		},
+		{
+			name: "toaster domain",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{Domain: "http://" + strings.ToUpper(id) + toastcloudtest.DomainSuffix, Authenticated: true}
+			},
+		},
+		{
+			name:          "port in the toaster domain",
+			toasterDomain: "http://" + toastcloudtest.ToasterDomain + ":8080",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{ID: id, Authenticated: true}
+			},
+		},
+		{
+			name:          "other port",
+			toasterDomain: "http://" + toastcloudtest.ToasterDomain + ":8080",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{Domain: "http://" + id + toastcloudtest.DomainSuffix + ":9090", Authenticated: true}
+			},
+			refused: true,
+		},
+		{
+			name: "custom domain",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{Domain: "http://api.custom.test", Authenticated: true}
+			},
+			refused: true,
+		},
+		{
+			name: "nested subdomain",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{Domain: "http://www." + id + toastcloudtest.DomainSuffix, Authenticated: true}
+			},
+			refused: true,
+		},
+		{
+			name: "lookalike domain",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{Domain: "http://" + id + toastcloudtest.DomainSuffix + ".evil.test", Authenticated: true}
+			},
+			refused: true,
+		},
+		{
+			name: "custom domain unauthenticated",
+			input: func(id string) *toastcloud.ToasterTransportInput {
+				return &toastcloud.ToasterTransportInput{Domain: "http://api.custom.test"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := toastcloudtest.NewServer()
+			defer srv.Close()
+
+			var opts []toastcloud.Option
+			if tt.toasterDomain != "" {
+				opts = append(opts, toastcloud.WithToasterDomain(tt.toasterDomain))
+			}
+			sess := srv.Session(opts...)
+			toaster := srv.AddToaster(toasterModel("transport"), nil)
+
+			input := tt.input(toaster.ID)
+			hc, err := sess.NewToasterHTTPClient(input)
+			if tt.refused {
+				if err == nil {
+					t.Fatal("NewToasterHTTPClient accepted to send credentials")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := hc.Get("/ping")
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			req := srv.AssertRequested(t, "GET", "/ping")
+			if sent := req.Header.Get("X-TOASTATE-AUTH") == srv.Token; sent != input.Authenticated {
+				t.Errorf("credentials sent: %v, want %v", sent, input.Authenticated)
+			}
+		})
+	}
+}
+
+func TestToasterTransportSticky(t *testing.T) {
+	srv, sess := newTestServer(t)
+	toaster := srv.AddToaster(models.Toaster{ExeCmd: []string{"./app"}, JoinableForSec: 60, MaxConcurrentJoiners: 10}, nil)
+
+	transport, err := sess.NewToasterTransport(&toastcloud.ToasterTransportInput{ID: toaster.ID, Sticky: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := &http.Client{Transport: transport}
+
+	send := func(method, path string, body io.Reader) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := hc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		return response
+	}
+
+	send("GET", "/first", nil)
+	first := transport.PinnedExecutionID()
+	if first == "" || first != transport.ExecutionID() {
+		t.Fatalf("pinned to %q after the first request served by %q", first, transport.ExecutionID())
+	}
+
+	send("GET", "/second", nil)
+	if transport.ExecutionID() != first {
+		t.Errorf("second request served by %s, want the pinned %s", transport.ExecutionID(), first)
+	}
+	if got := srv.AssertRequested(t, "GET", "/second").Header.Get(toastcloud.ExecutionIDHeader); got != first {
+		t.Errorf("second request sent to execution %q, want %q", got, first)
+	}
+
+	// A rewindable body is sent again to a new execution, which is pinned
+	// in turn.
+	srv.EndExecution(first)
+	response := send("POST", "/rewindable", bytes.NewReader([]byte("payload")))
+	second := transport.PinnedExecutionID()
+	if response.StatusCode != http.StatusOK || second == "" || second == first {
+		t.Fatalf("after a failed join: status %d, pinned to %q", response.StatusCode, second)
+	}
+	reqs := srv.RequestsTo("POST", "/rewindable")
+	if len(reqs) != 2 || string(reqs[1].Body) != "payload" || reqs[1].Header.Get(toastcloud.ExecutionIDHeader) != "" {
+		t.Errorf("requests after a failed join = %+v, want the body sent again to a new execution", reqs)
+	}
+
+	// A body that can not be rewound is not sent again: the failed join is
+	// returned, and the next request starts a new execution.
+	srv.EndExecution(second)
+	response = send("POST", "/streamed", io.MultiReader(strings.NewReader("payload")))
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("status of a failed join = %d, want %d", response.StatusCode, http.StatusNotFound)
+	}
+	if n := len(srv.RequestsTo("POST", "/streamed")); n != 1 {
+		t.Errorf("%d requests with a streamed body, want 1", n)
+	}
+	if pinned := transport.PinnedExecutionID(); pinned != "" {
+		t.Errorf("still pinned to %s after a failed join", pinned)
+	}
+
+	send("GET", "/third", nil)
+	if third := transport.PinnedExecutionID(); third == "" || third == first || third == second {
+		t.Errorf("pinned to %q after a new execution", third)
+	}
+
+	transport.Unpin()
+	if pinned := transport.PinnedExecutionID(); pinned != "" {
+		t.Errorf("pinned to %s after Unpin", pinned)
+	}
+}
@@ -1,6 +1,7 @@
 package toastcloud
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/toastate/toastate-sdk-go/common/models"
@@ -21,22 +22,26 @@ type signupResponse struct {
 }
 
 func (sess *Session) Signup(req *SignupInput) (*SignupOutput, error) {
+	return sess.SignupWithContext(context.Background(), req)
+}
+
+func (sess *Session) SignupWithContext(ctx context.Context, req *SignupInput) (*SignupOutput, error) {
 	resp := &signupResponse{}
 
-	apierr, err := sess.client.Post("/signup", req, resp)
+	apierr, err := sess.client.Post(ctx, "/signup", req, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.User == nil {
-		return nil, fmt.Errorf("The request was successfull but the remote API returned an empty body")
+		return nil, ErrEmptyResponse
 	}
 
 	return &SignupOutput{
@@ -68,6 +73,10 @@ type signinResponse struct {
 }
 
 func (sess *Session) Signin(req *SigninInput) (*SigninOutput, error) {
+	return sess.SigninWithContext(context.Background(), req)
+}
+
+func (sess *Session) SigninWithContext(ctx context.Context, req *SigninInput) (*SigninOutput, error) {
 	resp := &signinResponse{}
 
 	r := &signinRequest{
@@ -78,19 +87,19 @@ func (sess *Session) Signin(req *SigninInput) (*SigninOutput, error) {
 		SetToken:        true,
 	}
 
-	apierr, err := sess.client.Post("/signin", r, resp)
+	apierr, err := sess.client.Post(ctx, "/signin", r, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.Token == "" {
-		return nil, fmt.Errorf("The request was successfull but the remote API did not return an authentication token")
+		return nil, fmt.Errorf("%w: the remote API did not return an authentication token", ErrEmptyResponse)
 	}
 
 	return &SigninOutput{
@@ -111,22 +120,26 @@ type setupBillingResponse struct {
 }
 
 func (sess *Session) SetupBilling(req *SetupBillingInput) (*SetupBillingOutput, error) {
+	return sess.SetupBillingWithContext(context.Background(), req)
+}
+
+func (sess *Session) SetupBillingWithContext(ctx context.Context, req *SetupBillingInput) (*SetupBillingOutput, error) {
 	resp := &setupBillingResponse{}
 
-	apierr, err := sess.client.AuthedPost("/user/setupbilling", nil, resp)
+	apierr, err := sess.client.AuthedPost(ctx, "/user/setupbilling", nil, resp)
 	if err != nil {
 		return nil, err
 	}
 	if apierr != nil {
-		return nil, fmt.Errorf("APIERROR: status: %v; code: %v; message: %v", apierr.Status, apierr.Code, apierr.Message)
+		return nil, newAPIError(apierr)
 	}
 
 	if !resp.Success {
-		return nil, fmt.Errorf("The API returned a failure with a 200 HTTP status code which should not happen")
+		return nil, ErrUnexpectedFailure
 	}
 
 	if resp.URL == "" {
-		return nil, fmt.Errorf("The request was successfull but the remote API returned an empty body")
+		return nil, ErrEmptyResponse
 	}
 
 	return &SetupBillingOutput{